@@ -0,0 +1,586 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"net/url"
+	"os"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"llmpt/internal/bencode"
+	"llmpt/internal/config"
+	"llmpt/internal/database"
+	"llmpt/internal/tracker"
+)
+
+// Tracker 热路径基准测试
+// 运行: cd cmd/bench-tracker && go run main.go
+// 使用 testing.Benchmark 在普通程序中运行基准，输出每次操作的耗时与分配次数
+// Bench 2 需要 Redis（按 .env / 环境变量中的 REDIS_* 连接），连接失败时跳过
+// Bench 3 使用进程内 Peer 存储，对比大 Swarm 下 "IP:Port" 文本成员与 Compact 二进制成员构建响应的开销
+// Bench 4 通过 Handler.Announce 对进程内 Peer 存储执行完整的 HTTP announce（解析、存储、选取、编码）
+
+func main() {
+	testing.Init()
+
+	fmt.Println("⏱  Benchmarking Tracker Hot Paths...")
+	fmt.Println()
+
+	fmt.Println("📝 Bench 1: Announce Response Encoding (before = map + sorted dict, after = streaming Encoder)")
+	benchAnnounceEncoding()
+	fmt.Println()
+
+	fmt.Println("📝 Bench 2: Announce Redis Round Trips (before = multi-call, after = Redis.Announce; RTT/op counted by a client hook)")
+	benchAnnounceRoundTrips()
+	fmt.Println()
+
+	fmt.Println("📝 Bench 3: Large Swarm Compact Response (before = \"IP:Port\" members, after = compact binary members)")
+	benchLargeSwarmEncoding()
+	fmt.Println()
+
+	fmt.Println("📝 Bench 4: End-to-End HTTP Announce (Handler.Announce + in-memory peer store)")
+	benchAnnounceEndToEnd()
+	fmt.Println()
+
+	fmt.Println("✅ All benchmarks completed!")
+}
+
+// benchAnnounceEncoding 对比 Announce 响应编码的新旧实现
+func benchAnnounceEncoding() {
+	cases := []struct {
+		name    string
+		peers   []string
+		compact bool
+	}{
+		{"compact/50xIPv4", makePeers(50, 0), true},
+		{"compact/40xIPv4+10xIPv6", makePeers(40, 10), true},
+		{"dict/50xIPv4", makePeers(50, 0), false},
+	}
+
+	for _, c := range cases {
+		before := testing.Benchmark(func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				legacyEncodeAnnounce(c.peers, c.compact, 5, 10)
+			}
+		})
+
+		members := toMembers(c.peers)
+
+		enc := tracker.NewResponseEncoder()
+		after := testing.Benchmark(func(b *testing.B) {
+			b.ReportAllocs()
+			reply := tracker.AnnounceReply{
+				Interval:    1800 * time.Second,
+				MinInterval: 900 * time.Second,
+				Seeders:     5,
+				Leechers:    10,
+				Peers:       members,
+				Compact:     c.compact,
+			}
+			for i := 0; i < b.N; i++ {
+				enc.Reset(nil)
+				enc.EncodeAnnounce(&reply)
+			}
+		})
+
+		printResult(c.name+" before", before)
+		printResult(c.name+" after", after)
+	}
+}
+
+// benchAnnounceRoundTrips 对比 announce 访问 Redis 的两种方式的延迟分布与实际往返次数
+// before：CheckRateLimit + AddPeer + GetPeersForRequest + GetPeerCount（多次往返）
+// after：Redis.Announce（普通种子一次 EVALSHA）；after (hybrid) 为混合种子，另一半哈希的人数与样本多一次 Pipeline
+func benchAnnounceRoundTrips() {
+	cfg, err := config.Load()
+	if err != nil {
+		fmt.Printf("  skipped: failed to load config: %v\n", err)
+		return
+	}
+	r, err := database.NewRedis(&database.RedisOptions{
+		Mode:             cfg.Redis.Mode,
+		Addrs:            cfg.GetRedisAddrs(),
+		MasterName:       cfg.Redis.MasterName,
+		Password:         cfg.Redis.Password,
+		SentinelPassword: cfg.Redis.SentinelPassword,
+		DB:               cfg.Redis.DB,
+	})
+	if err != nil {
+		fmt.Printf("  skipped: %v\n", err)
+		return
+	}
+	defer r.Close()
+	counter := &roundTripCounter{}
+	r.Client.AddHook(counter)
+
+	const rounds = 5000
+	ctx := context.Background()
+	for _, swarmSize := range []int{50, 1000, 10000} {
+		infoHash := fmt.Sprintf("bench-%d-%d", swarmSize, time.Now().UnixNano())
+		peers := toMembers(makePeers(swarmSize, 0))
+
+		// 预先填满 Swarm，之后每轮重新 announce 其中一个 Peer，Swarm 大小保持不变
+		for i, peer := range peers {
+			if err := r.AddPeer(ctx, infoHash, []string{peer}, benchPeerID(i), "", i%3 == 0, time.Hour); err != nil {
+				fmt.Printf("  skipped: failed to populate swarm: %v\n", err)
+				return
+			}
+		}
+
+		// 混合种子的另一半：同一批 Peer 也登记在 v2 哈希下
+		linkedHash := infoHash + "-v2"
+		for i, peer := range peers {
+			if err := r.AddPeer(ctx, linkedHash, []string{peer}, benchPeerID(i), "", i%3 == 0, time.Hour); err != nil {
+				fmt.Printf("  skipped: failed to populate swarm: %v\n", err)
+				return
+			}
+		}
+
+		before, beforeTrips := measureRoundTrips(counter, rounds, func(i int) error {
+			peer := peers[i%swarmSize]
+			if _, err := r.CheckRateLimit(ctx, "bench", time.Minute, math.MaxInt32); err != nil {
+				return err
+			}
+			if err := r.AddPeer(ctx, infoHash, []string{peer}, benchPeerID(i%swarmSize), "", i%swarmSize%3 == 0, time.Hour); err != nil {
+				return err
+			}
+			if _, err := r.GetPeersForRequest(ctx, infoHash, 51, false); err != nil {
+				return err
+			}
+			_, _, err := r.GetPeerCount(ctx, infoHash)
+			return err
+		})
+
+		announce := func(linked []string) func(i int) error {
+			return func(i int) error {
+				_, err := r.Announce(ctx, &database.PeerAnnounce{
+					InfoHash:        infoHash,
+					Linked:          linked,
+					Peers:           []string{peers[i%swarmSize]},
+					PeerID:          benchPeerID(i % swarmSize),
+					Seeder:          i%swarmSize%3 == 0,
+					TTL:             time.Hour,
+					RateLimitIP:     "bench",
+					RateLimitWindow: time.Minute,
+					RateLimitBurst:  math.MaxInt32,
+					NumWant:         51,
+				})
+				return err
+			}
+		}
+		after, afterTrips := measureRoundTrips(counter, rounds, announce(nil))
+		hybrid, hybridTrips := measureRoundTrips(counter, rounds, announce([]string{linkedHash}))
+
+		printLatency(fmt.Sprintf("swarm=%d before", swarmSize), before, beforeTrips)
+		printLatency(fmt.Sprintf("swarm=%d after", swarmSize), after, afterTrips)
+		printLatency(fmt.Sprintf("swarm=%d after (hybrid)", swarmSize), hybrid, hybridTrips)
+
+		cleanupBenchSwarm(ctx, r, infoHash)
+		cleanupBenchSwarm(ctx, r, linkedHash)
+	}
+	fmt.Println("  (cluster 模式下按 IP 限流时每次 announce 再多 1 次往返：限流键与种子键不在同一 slot，在脚本之前单独检查)")
+}
+
+// roundTripCounter 统计发往 Redis 的往返次数（单条命令与 Pipeline 各计一次）
+type roundTripCounter struct {
+	n atomic.Int64
+}
+
+func (c *roundTripCounter) DialHook(next redis.DialHook) redis.DialHook { return next }
+
+func (c *roundTripCounter) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		c.n.Add(1)
+		return next(ctx, cmd)
+	}
+}
+
+func (c *roundTripCounter) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		c.n.Add(1)
+		return next(ctx, cmds)
+	}
+}
+
+// measureRoundTrips 与 measureLatency 相同，另外返回平均每次操作的 Redis 往返次数
+func measureRoundTrips(counter *roundTripCounter, rounds int, op func(i int) error) ([]time.Duration, float64) {
+	start := counter.n.Load()
+	latencies := measureLatency(rounds, op)
+	return latencies, float64(counter.n.Load()-start) / float64(rounds)
+}
+
+// benchLargeSwarmEncoding 大 Swarm（80% IPv4 + 20% IPv6）下每次 announce 随机取 50 个 Peer 并编码完整的 Compact 响应
+// before：成员为 "IP:Port" 文本，逐个解析拼接 Compact 列表后写出响应字典（旧版响应路径）；
+// after：成员即 Compact 格式，EncodeAnnounce 按长度直接拷贝
+// 两侧写出的响应字典相同，差别只在成员格式
+func benchLargeSwarmEncoding() {
+	ctx := context.Background()
+	for _, swarmSize := range []int{10000, 100000} {
+		text := makePeers(swarmSize*4/5, swarmSize/5)
+		members := toMembers(text)
+
+		textStore, memberStore := database.NewMemoryStore(), database.NewMemoryStore()
+		for i := range text {
+			textStore.AddPeer(ctx, "bench", []string{text[i]}, benchPeerID(i), "", false, time.Hour)
+			memberStore.AddPeer(ctx, "bench", []string{members[i]}, benchPeerID(i), "", false, time.Hour)
+		}
+
+		enc := bencode.NewEncoder(nil)
+		before := testing.Benchmark(func(b *testing.B) {
+			b.ReportAllocs()
+			v4, v6, scratch := make([]byte, 0, 50*6), make([]byte, 0, 50*18), make([]byte, 0, 18)
+			for i := 0; i < b.N; i++ {
+				peers, _ := textStore.RandomPeers(ctx, "bench", false, 50)
+				v4, v6 = v4[:0], v6[:0]
+				for _, peer := range peers {
+					out, isIPv6, ok := tracker.AppendCompactPeer(scratch[:0], peer)
+					switch {
+					case !ok:
+					case isIPv6:
+						v6 = append(v6, out...)
+					default:
+						v4 = append(v4, out...)
+					}
+				}
+				enc.Reset(nil)
+				writeCompactReply(enc, v4, v6)
+			}
+		})
+
+		respEnc := tracker.NewResponseEncoder()
+		after := testing.Benchmark(func(b *testing.B) {
+			b.ReportAllocs()
+			reply := tracker.AnnounceReply{
+				Interval:    1800 * time.Second,
+				MinInterval: 900 * time.Second,
+				Compact:     true,
+			}
+			for i := 0; i < b.N; i++ {
+				reply.Peers, _ = memberStore.RandomPeers(ctx, "bench", false, 50)
+				respEnc.Reset(nil)
+				respEnc.EncodeAnnounce(&reply)
+			}
+		})
+
+		printResult(fmt.Sprintf("swarm=%d before", swarmSize), before)
+		printResult(fmt.Sprintf("swarm=%d after", swarmSize), after)
+		fmt.Printf("  %-36s text %s, compact %s\n", fmt.Sprintf("swarm=%d member bytes", swarmSize),
+			formatBytes(totalLen(text)), formatBytes(totalLen(members)))
+	}
+}
+
+// compactReplyKeys 与 EncodeAnnounce 相同的响应键（不含 external ip）
+var compactReplyKeys = bencode.SortedKeys("complete", "incomplete", "interval", "min interval", "peers", "peers6")
+
+// writeCompactReply 按 EncodeAnnounce 的键顺序写出 Compact 响应，用于旧版成员格式的对比基线
+func writeCompactReply(enc *bencode.Encoder, v4, v6 []byte) {
+	enc.BeginDict()
+	enc.WriteKey(compactReplyKeys[0])
+	enc.WriteInt(0)
+	enc.WriteKey(compactReplyKeys[1])
+	enc.WriteInt(0)
+	enc.WriteKey(compactReplyKeys[2])
+	enc.WriteInt(1800)
+	enc.WriteKey(compactReplyKeys[3])
+	enc.WriteInt(900)
+	enc.WriteKey(compactReplyKeys[4])
+	enc.WriteBytes(v4)
+	if len(v6) > 0 {
+		enc.WriteKey(compactReplyKeys[5])
+		enc.WriteBytes(v6)
+	}
+	enc.End()
+}
+
+// benchAnnounceEndToEnd 通过 Handler.Announce 执行完整的 HTTP announce，Swarm 预先填满，
+// 每轮由其中一个 Peer 重新 announce（compact=1，默认返回 50 个 Peer），Swarm 大小保持不变
+//
+// after 为实测值；旧版编码器已不在处理器中，before 由实测值减去流式编码、加上同一响应的旧版编码得出（标注 derived）
+// announce 日志在基准期间重定向到 /dev/null
+func benchAnnounceEndToEnd() {
+	cfg, err := config.Load()
+	if err != nil {
+		fmt.Printf("  skipped: failed to load config: %v\n", err)
+		return
+	}
+	cfg.Server.WhitelistMode = false
+	cfg.Server.RateLimitBurst = math.MaxInt32
+	cfg.Server.PeerSelector = config.PeerSelectorRandom
+
+	devNull, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+	if err != nil {
+		fmt.Printf("  skipped: %v\n", err)
+		return
+	}
+	defer devNull.Close()
+
+	ctx := context.Background()
+	for _, swarmSize := range []int{50, 1000, 10000} {
+		store := database.NewMemoryStore()
+		handler := tracker.NewHandler(&database.DB{Peers: store}, cfg)
+
+		infoHash := strings.Repeat("ab", 20)
+		text := makePeers(swarmSize, 0)
+		members := toMembers(text)
+		requests := make([]*http.Request, swarmSize)
+		for i, peer := range text {
+			store.AddPeer(ctx, infoHash, []string{members[i]}, benchPeerID(i), "", i%3 == 0, time.Hour)
+
+			addrPort := netip.MustParseAddrPort(peer)
+			left := "0"
+			if i%3 != 0 {
+				left = "1024"
+			}
+			query := url.Values{
+				"info_hash": {infoHash},
+				"peer_id":   {benchPeerID(i)},
+				"port":      {strconv.Itoa(int(addrPort.Port()))},
+				"left":      {left},
+				"compact":   {"1"},
+			}
+			req := httptest.NewRequest(http.MethodGet, "/announce?"+query.Encode(), nil)
+			req.RemoteAddr = peer
+			requests[i] = req
+		}
+
+		w := &discardResponseWriter{header: make(http.Header)}
+		stdout := os.Stdout
+		os.Stdout = devNull
+		after := testing.Benchmark(func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				clear(w.header)
+				handler.Announce(w, requests[i%swarmSize])
+			}
+		})
+		os.Stdout = stdout
+
+		// 同一份 50 Peer 响应分别用新旧两种方式编码，差值即处理器中编码部分的变化
+		peers, _ := store.RandomPeers(ctx, infoHash, false, 50)
+		legacy := testing.Benchmark(func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				legacyEncodeAnnounce(text[:len(peers)], true, 17, 33)
+			}
+		})
+		enc := tracker.NewResponseEncoder()
+		streaming := testing.Benchmark(func(b *testing.B) {
+			b.ReportAllocs()
+			reply := tracker.AnnounceReply{Interval: 1800 * time.Second, MinInterval: 900 * time.Second, Peers: peers, Compact: true}
+			for i := 0; i < b.N; i++ {
+				enc.Reset(nil)
+				enc.EncodeAnnounce(&reply)
+			}
+		})
+
+		fmt.Printf("  %-36s %10d ns/op %8d B/op %6d allocs/op\n", fmt.Sprintf("swarm=%d before (derived)", swarmSize),
+			after.NsPerOp()-streaming.NsPerOp()+legacy.NsPerOp(),
+			after.AllocedBytesPerOp()-streaming.AllocedBytesPerOp()+legacy.AllocedBytesPerOp(),
+			after.AllocsPerOp()-streaming.AllocsPerOp()+legacy.AllocsPerOp())
+		printResult(fmt.Sprintf("swarm=%d after", swarmSize), after)
+	}
+}
+
+// discardResponseWriter 丢弃响应体的 http.ResponseWriter，Header 在每轮之间复用
+type discardResponseWriter struct {
+	header http.Header
+}
+
+func (w *discardResponseWriter) Header() http.Header         { return w.header }
+func (w *discardResponseWriter) Write(b []byte) (int, error) { return len(b), nil }
+func (w *discardResponseWriter) WriteHeader(int)             {}
+
+// totalLen 成员总字节数
+func totalLen(members []string) int {
+	n := 0
+	for _, m := range members {
+		n += len(m)
+	}
+	return n
+}
+
+// formatBytes 以 KB / MB 显示字节数
+func formatBytes(n int) string {
+	if n >= 1<<20 {
+		return fmt.Sprintf("%.1f MB", float64(n)/(1<<20))
+	}
+	return fmt.Sprintf("%.1f KB", float64(n)/(1<<10))
+}
+
+// benchPeerID 生成第 i 个基准 Peer 的 20 字节 peer_id
+func benchPeerID(i int) string {
+	return fmt.Sprintf("-BE0001-%012d", i)
+}
+
+// measureLatency 顺序执行 rounds 次操作，返回每次的耗时（出错时返回 nil）
+func measureLatency(rounds int, op func(i int) error) []time.Duration {
+	latencies := make([]time.Duration, 0, rounds)
+	for i := 0; i < rounds; i++ {
+		start := time.Now()
+		if err := op(i); err != nil {
+			fmt.Printf("  failed: %v\n", err)
+			return nil
+		}
+		latencies = append(latencies, time.Since(start))
+	}
+	return latencies
+}
+
+// printLatency 打印延迟分布与平均往返次数
+func printLatency(name string, latencies []time.Duration, roundTrips float64) {
+	if len(latencies) == 0 {
+		return
+	}
+	slices.Sort(latencies)
+	percentile := func(p float64) time.Duration {
+		return latencies[int(p*float64(len(latencies)-1))]
+	}
+	fmt.Printf("  %-36s p50 %9s  p99 %9s  max %9s  %4.1f RTT/op\n",
+		name, percentile(0.50), percentile(0.99), latencies[len(latencies)-1], roundTrips)
+}
+
+// cleanupBenchSwarm 删除基准测试写入的键
+func cleanupBenchSwarm(ctx context.Context, r *database.Redis, infoHash string) {
+	r.PurgeTorrent(ctx, infoHash)
+	r.Client.Del(ctx, "tracker:ratelimit:bench")
+}
+
+// printResult 打印单个基准结果
+func printResult(name string, r testing.BenchmarkResult) {
+	fmt.Printf("  %-36s %10d ns/op %8d B/op %6d allocs/op\n",
+		name, r.NsPerOp(), r.AllocedBytesPerOp(), r.AllocsPerOp())
+}
+
+// makePeers 生成指定数量的 IPv4 / IPv6 Peer（"IP:Port" 文本）
+func makePeers(v4, v6 int) []string {
+	peers := make([]string, 0, v4+v6)
+	for i := 0; i < v4; i++ {
+		ip := netip.AddrFrom4([4]byte{10, byte(i >> 16), byte(i >> 8), byte(i)})
+		peers = append(peers, netip.AddrPortFrom(ip, uint16(6881+i%1000)).String())
+	}
+	for i := 0; i < v6; i++ {
+		peers = append(peers, net.JoinHostPort(fmt.Sprintf("2001:db8::%x", i+1), strconv.Itoa(6881+i%1000)))
+	}
+	return peers
+}
+
+// toMembers 将 "IP:Port" 文本转换为 Peer 存储格式
+func toMembers(peers []string) []string {
+	members := make([]string, len(peers))
+	for i, peer := range peers {
+		members[i] = database.PeerMember(netip.MustParseAddrPort(peer))
+	}
+	return members
+}
+
+// legacyEncodeAnnounce 旧版 sendSuccess 的编码逻辑（map + legacyDict），作为对比基线
+func legacyEncodeAnnounce(peers []string, compact bool, seeders, leechers int64) []byte {
+	response := make(map[string][]byte)
+
+	response["interval"] = legacyInt(1800)
+	response["min interval"] = legacyInt(900)
+	response["complete"] = legacyInt(seeders)
+	response["incomplete"] = legacyInt(leechers)
+
+	ipv4Peers, ipv6Peers := tracker.SeparatePeersByIPVersion(peers)
+
+	if compact {
+		compactPeers, _ := tracker.CompactPeersIPv4(ipv4Peers)
+		response["peers"] = legacyBytes(compactPeers)
+		if len(ipv6Peers) > 0 {
+			compactPeers6, _ := tracker.CompactPeersIPv6(ipv6Peers)
+			response["peers6"] = legacyBytes(compactPeers6)
+		}
+	} else {
+		encodeList := func(list []string) []byte {
+			items := make([][]byte, 0, len(list))
+			for _, peer := range list {
+				host, portStr, err := net.SplitHostPort(peer)
+				if err != nil {
+					continue
+				}
+				port, _ := strconv.Atoi(portStr)
+
+				peerDict := make(map[string][]byte)
+				peerDict["ip"] = legacyString(host)
+				peerDict["port"] = legacyInt(int64(port))
+				peerDict["peer id"] = legacyString("")
+				items = append(items, legacyDict(peerDict))
+			}
+			return legacyList(items)
+		}
+		response["peers"] = encodeList(ipv4Peers)
+		if len(ipv6Peers) > 0 {
+			response["peers6"] = encodeList(ipv6Peers)
+		}
+	}
+
+	return legacyDict(response)
+}
+
+// 以下为已删除的 internal/tracker/bencode.go 中的旧版编码函数，仅保留作 Bench 1 的对比基线
+
+// legacyString 编码字符串: <长度>:<内容>
+func legacyString(s string) []byte {
+	return legacyBytes([]byte(s))
+}
+
+// legacyBytes 编码字节数组
+func legacyBytes(b []byte) []byte {
+	buf := make([]byte, 0, len(b)+20)
+	buf = strconv.AppendInt(buf, int64(len(b)), 10)
+	buf = append(buf, ':')
+	buf = append(buf, b...)
+	return buf
+}
+
+// legacyInt 编码整数: i<数字>e
+func legacyInt(n int64) []byte {
+	buf := make([]byte, 0, 24)
+	buf = append(buf, 'i')
+	buf = strconv.AppendInt(buf, n, 10)
+	buf = append(buf, 'e')
+	return buf
+}
+
+// legacyList 编码列表: l<元素>e
+func legacyList(items [][]byte) []byte {
+	buf := bytes.NewBuffer([]byte("l"))
+	for _, item := range items {
+		buf.Write(item)
+	}
+	buf.WriteByte('e')
+	return buf.Bytes()
+}
+
+// legacyDict 编码字典: d<key><value>e，键按字典序排序
+func legacyDict(dict map[string][]byte) []byte {
+	keys := make([]string, 0, len(dict))
+	for k := range dict {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	buf := bytes.NewBuffer([]byte("d"))
+	for _, key := range keys {
+		buf.Write(legacyString(key))
+		buf.Write(dict[key])
+	}
+	buf.WriteByte('e')
+	return buf.Bytes()
+}
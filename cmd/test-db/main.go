@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"net/netip"
 	"time"
 
 	"llmpt/internal/config"
@@ -42,7 +43,8 @@ func testMongoDB(db *database.DB) {
 	testTorrent := &models.Torrent{
 		Name:        "Test-Llama-3-8B",
 		InfoHash:    "1234567890abcdef1234567890abcdef12345678",
-		TotalSize:   15000000000, // 15GB
+		InfoHashV2:  "abcdef1234567890abcdef1234567890abcdef1234567890abcdef1234567890", // 混合种子
+		TotalSize:   15000000000,                                                        // 15GB
 		FileCount:   120,
 		MagnetLink:  "magnet:?xt=urn:btih:1234567890abcdef1234567890abcdef12345678",
 		PieceLength: 8388608, // 8MB
@@ -80,17 +82,18 @@ func testRedis(db *database.DB) {
 	ctx := context.Background()
 	testInfoHash := "test1234567890abcdef1234567890abcdef12"
 
-	// 添加 Peer
+	// 添加 Peer（存储格式为 Compact 二进制）
 	peers := []string{
-		"192.168.1.100:6881",
-		"192.168.1.101:6881",
-		"192.168.1.102:6881",
+		database.PeerMember(netip.MustParseAddrPort("192.168.1.100:6881")),
+		database.PeerMember(netip.MustParseAddrPort("192.168.1.101:6881")),
+		database.PeerMember(netip.MustParseAddrPort("192.168.1.102:6881")),
 	}
 
 	for i, peer := range peers {
 		// 前两个当 seeder，后一个当 leecher
 		isSeeder := i < 2
-		err := db.Redis.AddPeer(ctx, testInfoHash, peer, isSeeder, 30*time.Minute)
+		peerID := fmt.Sprintf("-TEST00-%012d", i)
+		err := db.Redis.AddPeer(ctx, testInfoHash, []string{peer}, peerID, "", isSeeder, 30*time.Minute)
 		if err != nil {
 			log.Printf("Failed to add peer: %v", err)
 		}
@@ -102,7 +105,21 @@ func testRedis(db *database.DB) {
 	if err != nil {
 		log.Printf("Failed to get peers: %v", err)
 	} else {
-		fmt.Printf("✓ Found %d peers: %v\n", len(foundPeers), foundPeers)
+		found := make([]string, 0, len(foundPeers))
+		for _, peer := range foundPeers {
+			found = append(found, database.PeerMemberString(peer))
+		}
+		fmt.Printf("✓ Found %d peers: %v\n", len(foundPeers), found)
+	}
+
+	// 获取 Peer 的 peer_id（非 Compact 响应使用）
+	peerIDs, err := db.Redis.GetPeerIDs(ctx, testInfoHash, peers)
+	if err != nil {
+		log.Printf("Failed to get peer ids: %v", err)
+	} else {
+		for peer, peerID := range peerIDs {
+			fmt.Printf("✓ Peer id: %s -> %s\n", database.PeerMemberString(peer), peerID)
+		}
 	}
 
 	// 获取 Peer 数量
@@ -130,9 +147,6 @@ func testRedis(db *database.DB) {
 	}
 
 	// 清理测试数据
-	db.Redis.Client.Del(ctx, fmt.Sprintf("tracker:seeders:%s", testInfoHash))
-	db.Redis.Client.Del(ctx, fmt.Sprintf("tracker:leechers:%s", testInfoHash))
-	db.Redis.Client.SRem(ctx, "tracker:active_torrents", testInfoHash)
-	db.Redis.Client.Del(ctx, fmt.Sprintf("tracker:stats:%s", testInfoHash))
+	db.Redis.PurgeTorrent(ctx, testInfoHash)
 	fmt.Println("✓ Cleaned up test data")
 }
@@ -2,13 +2,24 @@ package main
 
 import (
 	"bytes"
+	"encoding/binary"
 	"encoding/hex"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
+	"net/http/httptest"
+	"net/netip"
 	"net/url"
+	"os"
+	"path/filepath"
 	"strings"
+	"time"
 
+	"llmpt/internal/bencode"
+	"llmpt/internal/config"
+	"llmpt/internal/database"
+	"llmpt/internal/models"
 	"llmpt/internal/tracker"
 )
 
@@ -31,13 +42,34 @@ func main() {
 	testCompactPeerIPv6()
 	fmt.Println()
 
-	// 测试 4: Announce 请求（需要先启动 Tracker Server）
-	fmt.Println("🌐 Test 4: Announce Request")
+	// 测试 4: peer_id 客户端识别
+	fmt.Println("🔍 Test 4: Peer ID Client Parsing")
+	testParsePeerID()
+	fmt.Println()
+
+	// 测试 5: GeoIP / ASN 查询与热加载（使用生成的 mmdb 测试数据）
+	fmt.Println("🗺️  Test 5: GeoIP Lookup")
+	testGeoIP()
+	fmt.Println()
+
+	// 测试 6: 通过内存存储执行 Announce（不需要 Redis 与 Tracker Server）
+	fmt.Println("💾 Test 6: Announce via Memory Store")
+	testMemoryAnnounce()
+	fmt.Println()
+
+	// 测试 7: Announce 请求（需要先启动 Tracker Server）
+	fmt.Println("🌐 Test 7: Announce Request")
 	fmt.Println("请先启动 Tracker Server: cd cmd/tracker && go run main.go")
 	fmt.Println("然后运行测试: testAnnounce()")
 	// testAnnounce()
 	fmt.Println()
 
+	// 测试 8: UDP Announce 请求（BEP-0015，需要先以 UDP_PORT=6969 启动 Tracker Server）
+	fmt.Println("🌐 Test 8: UDP Announce Request")
+	fmt.Println("然后运行测试: testUDPAnnounce()")
+	// testUDPAnnounce()
+	fmt.Println()
+
 	fmt.Println("✅ All tests completed!")
 }
 
@@ -45,22 +77,147 @@ func main() {
 func testBencode() {
 	// 测试字符串编码
 	str := "spam"
-	encoded := tracker.EncodeString(str)
+	encoded, _ := bencode.Marshal(str)
 	fmt.Printf("String: %s -> %s\n", str, string(encoded))
 
 	// 测试整数编码
 	num := int64(42)
-	encoded = tracker.EncodeInt(num)
+	encoded, _ = bencode.Marshal(num)
 	fmt.Printf("Int: %d -> %s\n", num, string(encoded))
 
-	// 测试字典编码
-	dict := map[string][]byte{
-		"interval":   tracker.EncodeInt(1800),
-		"complete":   tracker.EncodeInt(5),
-		"incomplete": tracker.EncodeInt(10),
+	// 测试字典编码（键按字节序排序）
+	dict := map[string]int64{
+		"interval":   1800,
+		"complete":   5,
+		"incomplete": 10,
+	}
+	encoded, err := bencode.Marshal(dict)
+	if err != nil {
+		fmt.Printf("❌ Marshal failed: %v\n", err)
+		return
 	}
-	encoded = tracker.EncodeDict(dict)
 	fmt.Printf("Dict: %s\n", string(encoded))
+
+	// 测试解码（往返）
+	decoded, err := bencode.Decode(encoded)
+	if err != nil {
+		fmt.Printf("❌ Decode failed: %v\n", err)
+		return
+	}
+	fmt.Printf("Decoded: %v\n", decoded)
+
+	// 测试非规范形式拒绝
+	for _, invalid := range []string{"i03e", "i-0e", "d3:fooi1e3:bari2ee"} {
+		if _, err := bencode.Decode([]byte(invalid)); err == nil {
+			fmt.Printf("❌ Non-canonical input accepted: %s\n", invalid)
+			return
+		}
+	}
+
+	// 测试提取 info 字典原始字节
+	raw, err := bencode.RawValue([]byte("d8:announce3:url4:infod4:name4:testee"), "info")
+	if err != nil || string(raw) != "d4:name4:teste" {
+		fmt.Printf("❌ RawValue failed: %q %v\n", raw, err)
+		return
+	}
+	fmt.Println("✅ Bencode decode test passed")
+
+	// 测试结构体 Marshal/Unmarshal
+	resp := models.AnnounceResponse{
+		Interval:    1800,
+		MinInterval: 900,
+		Complete:    1,
+		Peers:       []models.PeerInfo{{IP: "192.168.1.100", Port: 6881}},
+	}
+	encoded, err = bencode.Marshal(resp)
+	if err != nil {
+		fmt.Printf("❌ Marshal failed: %v\n", err)
+		return
+	}
+	fmt.Printf("Marshal: %s\n", string(encoded))
+
+	var roundTrip models.AnnounceResponse
+	if err := bencode.Unmarshal(encoded, &roundTrip); err != nil || len(roundTrip.Peers) != 1 || roundTrip.Peers[0].Port != 6881 {
+		fmt.Printf("❌ Unmarshal failed: %+v %v\n", roundTrip, err)
+		return
+	}
+	fmt.Println("✅ Bencode Marshal/Unmarshal test passed")
+}
+
+// testParsePeerID 测试 Azureus / Shadow 风格 peer_id 解析
+func testParsePeerID() {
+	cases := map[string]string{
+		"-qB4650-abcdefghijkl": "qBittorrent 4.6.5",
+		"-TR3000-abcdefghijkl": "Transmission 3.0",
+		"-MC0100-abcdefghijkl": "model-cli 0.1",
+		"S58B-----abcdefghijk": "Shadow 5.8.11",
+		"test_peer_00000001":   "unknown",
+	}
+	for peerID, want := range cases {
+		got := tracker.ParsePeerID(peerID).String()
+		if got != want {
+			fmt.Printf("❌ ParsePeerID(%q) = %q, want %q\n", peerID, got, want)
+			return
+		}
+		fmt.Printf("%s -> %s\n", peerID[:8], got)
+	}
+	fmt.Println("✅ Peer ID parsing test passed")
+}
+
+// testGeoIP 测试 mmdb 国家 / ASN 查询，以及文件变化后的热加载
+func testGeoIP() {
+	dir, err := os.MkdirTemp("", "llmpt-geoip")
+	if err != nil {
+		fmt.Printf("❌ Failed to create temp dir: %v\n", err)
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "test.mmdb")
+	if err := writeTestMMDB(path, []mmdbEntry{
+		{Network: "10.1.0.0/16", Country: "CN", ASN: 64512, ASOrg: "DC-A"},
+		{Network: "2001:db8::/32", Country: "US", ASN: 64513, ASOrg: "DC-B"},
+	}); err != nil {
+		fmt.Printf("❌ Failed to write mmdb fixture: %v\n", err)
+		return
+	}
+
+	geoip := tracker.NewGeoIP([]string{path})
+	if err := geoip.Load(); err != nil {
+		fmt.Printf("❌ Failed to load mmdb fixture: %v\n", err)
+		return
+	}
+
+	cases := map[string]tracker.GeoInfo{
+		"10.1.2.3":    {Country: "CN", ASN: 64512, ASOrg: "DC-A"},
+		"2001:db8::1": {Country: "US", ASN: 64513, ASOrg: "DC-B"},
+		"8.8.8.8":     {},
+	}
+	for ip, want := range cases {
+		got := geoip.Lookup(netip.MustParseAddr(ip))
+		if got != want {
+			fmt.Printf("❌ Lookup(%s) = %+v, want %+v\n", ip, got, want)
+			return
+		}
+		fmt.Printf("%s -> %+v\n", ip, got)
+	}
+
+	// 覆盖文件并推后修改时间，模拟运维更新数据库
+	if err := writeTestMMDB(path, []mmdbEntry{
+		{Network: "10.1.0.0/16", Country: "JP", ASN: 64514, ASOrg: "DC-C"},
+	}); err != nil {
+		fmt.Printf("❌ Failed to rewrite mmdb fixture: %v\n", err)
+		return
+	}
+	later := time.Now().Add(time.Minute)
+	os.Chtimes(path, later, later)
+	geoip.Reload()
+
+	if got := geoip.Lookup(netip.MustParseAddr("10.1.2.3")); got.Country != "JP" {
+		fmt.Printf("❌ Hot reload failed: Lookup(10.1.2.3) = %+v\n", got)
+		return
+	}
+	fmt.Println("✅ GeoIP lookup test passed")
 }
 
 // testCompactPeer 测试紧凑格式 Peer 编码
@@ -263,6 +420,19 @@ func testAnnounce() {
 		}
 	}
 
+	// BEP-0024：external ip 为 4 或 16 字节的二进制地址
+	if externalIP, err := bencode.RawValue(body, "external ip"); err == nil {
+		var ip []byte
+		if err := bencode.Unmarshal(externalIP, &ip); err != nil || (len(ip) != 4 && len(ip) != 16) {
+			fmt.Printf("❌ Invalid external ip: %q\n", externalIP)
+			return
+		}
+		fmt.Printf("🌐 External IP: %s\n", net.IP(ip))
+	} else {
+		fmt.Println("❌ Missing external ip")
+		return
+	}
+
 	fmt.Println("✅ Announce test passed")
 
 	// 测试多个客户端
@@ -270,6 +440,103 @@ func testAnnounce() {
 	testMultiplePeers()
 }
 
+// testMemoryAnnounce 使用 MemoryStore 与 httptest 直接调用 Handler.Announce：
+// 下载者与做种者的计数、Peer 混合选取、stopped 移除，覆盖 announce 的完整流程
+func testMemoryAnnounce() {
+	for _, selector := range []string{config.PeerSelectorRandom, config.PeerSelectorFresh} {
+		if !testMemoryAnnounceWith(selector) {
+			return
+		}
+	}
+	fmt.Println("✅ Memory store announce test passed")
+}
+
+// testMemoryAnnounceWith 使用指定的 Peer 选取策略执行 testMemoryAnnounce 的流程，失败时返回 false
+func testMemoryAnnounceWith(selector string) bool {
+	cfg, err := config.Load()
+	if err != nil {
+		fmt.Printf("❌ Load config failed: %v\n", err)
+		return false
+	}
+	cfg.Server.PeerSelector = selector
+	handler := tracker.NewHandler(&database.DB{Peers: database.NewMemoryStore()}, cfg)
+
+	announce := func(peerID, ip string, left int, event string) (map[string]interface{}, error) {
+		params := url.Values{}
+		params.Add("info_hash", "memory_info_hash_001")
+		params.Add("peer_id", peerID)
+		params.Add("port", "6881")
+		params.Add("uploaded", "0")
+		params.Add("downloaded", "0")
+		params.Add("left", fmt.Sprintf("%d", left))
+		params.Add("compact", "1")
+		if event != "" {
+			params.Add("event", event)
+		}
+		r := httptest.NewRequest(http.MethodGet, "/announce?"+params.Encode(), nil)
+		r.RemoteAddr = ip + ":50000"
+		w := httptest.NewRecorder()
+		handler.Announce(w, r)
+
+		decoded, err := bencode.Decode(w.Body.Bytes())
+		if err != nil {
+			return nil, err
+		}
+		dict, _ := decoded.(map[string]interface{})
+		if reason, ok := dict["failure reason"]; ok {
+			return nil, fmt.Errorf("%s", reason)
+		}
+		return dict, nil
+	}
+
+	// 3 个下载者与 1 个做种者
+	for i := 1; i <= 3; i++ {
+		if _, err := announce(fmt.Sprintf("memory_leecher_%05d", i), fmt.Sprintf("10.0.0.%d", i), 1000, "started"); err != nil {
+			fmt.Printf("❌ Leecher %d announce failed: %v\n", i, err)
+			return false
+		}
+	}
+	resp, err := announce("memory_seeder_000001", "10.0.0.100", 0, "started")
+	if err != nil {
+		fmt.Printf("❌ Seeder announce failed: %v\n", err)
+		return false
+	}
+	peers, _ := resp["peers"].(string)
+	if resp["complete"] != int64(1) || resp["incomplete"] != int64(3) || len(peers) != 3*database.PeerMemberIPv4Len {
+		fmt.Printf("❌ Unexpected seeder response: complete=%v incomplete=%v peers=%d bytes\n",
+			resp["complete"], resp["incomplete"], len(peers))
+		return false
+	}
+	decompacted, _ := tracker.DecompactPeersIPv4([]byte(peers))
+	fmt.Printf("✅ [%s] Seeder sees 3 leechers: %v\n", selector, decompacted)
+
+	// 下载者按比例拿到做种者和其他下载者（不含自己）
+	resp, err = announce("memory_leecher_00001", "10.0.0.1", 1000, "")
+	if err != nil {
+		fmt.Printf("❌ Leecher announce failed: %v\n", err)
+		return false
+	}
+	peers, _ = resp["peers"].(string)
+	decompacted, _ = tracker.DecompactPeersIPv4([]byte(peers))
+	if len(decompacted) != 3 {
+		fmt.Printf("❌ Leecher expected 3 peers, got %v\n", decompacted)
+		return false
+	}
+	fmt.Printf("✅ [%s] Leecher sees seeder and other leechers: %v\n", selector, decompacted)
+
+	// stopped 移除 Peer
+	if _, err := announce("memory_leecher_00002", "10.0.0.2", 1000, "stopped"); err != nil {
+		fmt.Printf("❌ Stopped announce failed: %v\n", err)
+		return false
+	}
+	resp, err = announce("memory_seeder_000001", "10.0.0.100", 0, "")
+	if err != nil || resp["incomplete"] != int64(2) {
+		fmt.Printf("❌ Stopped peer not removed: incomplete=%v err=%v\n", resp["incomplete"], err)
+		return false
+	}
+	return true
+}
+
 // testMultiplePeers 测试多个 Peer
 func testMultiplePeers() {
 	infoHashBytes := []byte("test_info_hash_12345")
@@ -312,3 +579,64 @@ func testMultiplePeers() {
 
 	fmt.Println("✅ Multiple peers test completed")
 }
+
+// testUDPAnnounce 测试 UDP Tracker 的 connect + announce 流程（BEP-0015）
+func testUDPAnnounce() {
+	conn, err := net.Dial("udp", "localhost:6969")
+	if err != nil {
+		fmt.Printf("❌ Dial failed: %v\n", err)
+		return
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	// 1. connect
+	req := make([]byte, 16)
+	binary.BigEndian.PutUint64(req[0:8], 0x41727101980)
+	binary.BigEndian.PutUint32(req[8:12], 0)
+	binary.BigEndian.PutUint32(req[12:16], 12345)
+	if _, err := conn.Write(req); err != nil {
+		fmt.Printf("❌ Connect write failed: %v\n", err)
+		return
+	}
+
+	resp := make([]byte, 2048)
+	n, err := conn.Read(resp)
+	if err != nil || n < 16 || binary.BigEndian.Uint32(resp[0:4]) != 0 {
+		fmt.Printf("❌ Connect failed: n=%d err=%v\n", n, err)
+		return
+	}
+	connectionID := binary.BigEndian.Uint64(resp[8:16])
+	fmt.Printf("✅ Connected, connection_id=%x\n", connectionID)
+
+	// 2. announce
+	req = make([]byte, 98)
+	binary.BigEndian.PutUint64(req[0:8], connectionID)
+	binary.BigEndian.PutUint32(req[8:12], 1)
+	binary.BigEndian.PutUint32(req[12:16], 12346)
+	copy(req[16:36], "test_info_hash_12345")
+	copy(req[36:56], "test_peer_udp_000001")
+	binary.BigEndian.PutUint64(req[64:72], 1000000) // left
+	binary.BigEndian.PutUint32(req[80:84], 2)       // started
+	binary.BigEndian.PutUint32(req[92:96], 0xFFFFFFFF)
+	binary.BigEndian.PutUint16(req[96:98], 6881)
+	if _, err := conn.Write(req); err != nil {
+		fmt.Printf("❌ Announce write failed: %v\n", err)
+		return
+	}
+
+	n, err = conn.Read(resp)
+	if err != nil || n < 20 {
+		fmt.Printf("❌ Announce failed: n=%d err=%v\n", n, err)
+		return
+	}
+	if action := binary.BigEndian.Uint32(resp[0:4]); action != 1 {
+		fmt.Printf("❌ Tracker returned an error: %s\n", string(resp[8:n]))
+		return
+	}
+
+	peers, _ := tracker.DecompactPeersIPv4(resp[20:n])
+	fmt.Printf("✅ UDP announce: interval=%d leechers=%d seeders=%d peers=%v\n",
+		binary.BigEndian.Uint32(resp[8:12]), binary.BigEndian.Uint32(resp[12:16]),
+		binary.BigEndian.Uint32(resp[16:20]), peers)
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net/netip"
+	"os"
+	"time"
+)
+
+// 最小化的 MaxMind DB（mmdb）写入器，只用于生成 GeoIP 测试数据
+//
+// 格式：IPv6 搜索树（24 位记录，IPv4 位于 ::/96 下）+ 16 字节分隔符 + 数据段 + 元数据
+// 只支持互不重叠的网段，字符串长度不超过 284 字节
+
+// mmdbEntry 测试数据中的一个网段
+type mmdbEntry struct {
+	Network string // CIDR，如 10.1.0.0/16、2001:db8::/32
+	Country string // ISO 国家代码
+	ASN     uint32
+	ASOrg   string
+}
+
+// mmdb 数据段类型
+const (
+	mmdbString = 2
+	mmdbUint16 = 5
+	mmdbUint32 = 6
+	mmdbMap    = 7
+	mmdbUint64 = 9
+	mmdbArray  = 11
+)
+
+// mmdbNode 搜索树节点，每侧要么指向子节点，要么指向数据（dataOffset+1，0 表示空）
+type mmdbNode struct {
+	child [2]*mmdbNode
+	data  [2]int
+}
+
+// writeTestMMDB 生成包含 entries 的 mmdb 文件
+func writeTestMMDB(path string, entries []mmdbEntry) error {
+	root := &mmdbNode{}
+	var data []byte
+
+	for _, entry := range entries {
+		prefix, err := netip.ParsePrefix(entry.Network)
+		if err != nil {
+			return err
+		}
+
+		offset := len(data)
+		data = mmdbAppendControl(data, mmdbMap, 3)
+		data = mmdbAppendString(data, "country")
+		data = mmdbAppendControl(data, mmdbMap, 1)
+		data = mmdbAppendString(data, "iso_code")
+		data = mmdbAppendString(data, entry.Country)
+		data = mmdbAppendString(data, "autonomous_system_number")
+		data = mmdbAppendUint(data, mmdbUint32, uint64(entry.ASN))
+		data = mmdbAppendString(data, "autonomous_system_organization")
+		data = mmdbAppendString(data, entry.ASOrg)
+
+		// IPv4 网段放在 ::/96 之下
+		addr := prefix.Addr().As16()
+		bits := prefix.Bits()
+		if prefix.Addr().Is4() {
+			addr = [16]byte{}
+			v4 := prefix.Addr().As4()
+			copy(addr[12:], v4[:])
+			bits += 96
+		}
+
+		node := root
+		for i := 0; i < bits-1; i++ {
+			bit := addr[i/8] >> (7 - i%8) & 1
+			if node.child[bit] == nil {
+				node.child[bit] = &mmdbNode{}
+			}
+			node = node.child[bit]
+		}
+		last := bits - 1
+		node.data[addr[last/8]>>(7-last%8)&1] = offset + 1
+	}
+
+	// 按先序编号
+	var nodes []*mmdbNode
+	index := make(map[*mmdbNode]int)
+	var walk func(n *mmdbNode)
+	walk = func(n *mmdbNode) {
+		index[n] = len(nodes)
+		nodes = append(nodes, n)
+		for _, child := range n.child {
+			if child != nil {
+				walk(child)
+			}
+		}
+	}
+	walk(root)
+
+	nodeCount := len(nodes)
+	var buf []byte
+	for _, n := range nodes {
+		for side := 0; side < 2; side++ {
+			record := nodeCount // 空记录
+			switch {
+			case n.child[side] != nil:
+				record = index[n.child[side]]
+			case n.data[side] > 0:
+				record = nodeCount + 16 + n.data[side] - 1
+			}
+			buf = append(buf, byte(record>>16), byte(record>>8), byte(record))
+		}
+	}
+	buf = append(buf, make([]byte, 16)...)
+	buf = append(buf, data...)
+
+	buf = append(buf, "\xAB\xCD\xEFMaxMind.com"...)
+	buf = mmdbAppendControl(buf, mmdbMap, 9)
+	buf = mmdbAppendString(buf, "binary_format_major_version")
+	buf = mmdbAppendUint(buf, mmdbUint16, 2)
+	buf = mmdbAppendString(buf, "binary_format_minor_version")
+	buf = mmdbAppendUint(buf, mmdbUint16, 0)
+	buf = mmdbAppendString(buf, "build_epoch")
+	buf = mmdbAppendUint(buf, mmdbUint64, uint64(time.Now().Unix()))
+	buf = mmdbAppendString(buf, "database_type")
+	buf = mmdbAppendString(buf, "llmpt-Test-Geo")
+	buf = mmdbAppendString(buf, "description")
+	buf = mmdbAppendControl(buf, mmdbMap, 1)
+	buf = mmdbAppendString(buf, "en")
+	buf = mmdbAppendString(buf, "llmpt test fixture")
+	buf = mmdbAppendString(buf, "ip_version")
+	buf = mmdbAppendUint(buf, mmdbUint16, 6)
+	buf = mmdbAppendString(buf, "languages")
+	buf = mmdbAppendControl(buf, mmdbArray, 1)
+	buf = mmdbAppendString(buf, "en")
+	buf = mmdbAppendString(buf, "node_count")
+	buf = mmdbAppendUint(buf, mmdbUint32, uint64(nodeCount))
+	buf = mmdbAppendString(buf, "record_size")
+	buf = mmdbAppendUint(buf, mmdbUint16, 24)
+
+	return os.WriteFile(path, buf, 0o644)
+}
+
+// mmdbAppendControl 追加控制字节：高 3 位为类型（大于 7 的扩展类型写在下一个字节），低 5 位为长度
+// 长度 29~284 时低 5 位为 29，实际长度减 29 写在随后的一个字节
+func mmdbAppendControl(dst []byte, typ, size int) []byte {
+	if size >= 285 {
+		panic(fmt.Sprintf("mmdb fixture: size %d is not supported", size))
+	}
+	sizeBits := size
+	if size >= 29 {
+		sizeBits = 29
+	}
+	if typ > 7 {
+		dst = append(dst, byte(sizeBits), byte(typ-7))
+	} else {
+		dst = append(dst, byte(typ<<5|sizeBits))
+	}
+	if size >= 29 {
+		dst = append(dst, byte(size-29))
+	}
+	return dst
+}
+
+func mmdbAppendString(dst []byte, s string) []byte {
+	dst = mmdbAppendControl(dst, mmdbString, len(s))
+	return append(dst, s...)
+}
+
+// mmdbAppendUint 无符号整数以大端序、去掉前导零字节的形式存储
+func mmdbAppendUint(dst []byte, typ int, v uint64) []byte {
+	var b [8]byte
+	binary.BigEndian.PutUint64(b[:], v)
+	n := 0
+	for n < 8 && b[n] == 0 {
+		n++
+	}
+	dst = mmdbAppendControl(dst, typ, 8-n)
+	return append(dst, b[n:]...)
+}
@@ -7,6 +7,7 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
 
@@ -37,12 +38,66 @@ func main() {
 	// 创建 Tracker 处理器
 	handler := tracker.NewHandler(db, cfg)
 
-	// 启动后台清理任务（时间间隔紧跟 AnnounceInterval 配置）
-	go handler.StartCleanup(ctx, cfg.Server.AnnounceInterval)
+	// 加载种子注册表（白名单、混合种子 v1/v2 关联），订阅登记/删除事件并定期从 MongoDB 刷新
+	if err := handler.Registry().Load(ctx); err != nil {
+		log.Fatalf("Failed to load torrent registry: %v", err)
+	}
+	go handler.Registry().StartSync(ctx, cfg.Server.RegistrySyncInterval)
+
+	// 加载本地 GeoIP / ASN 数据库（可选），文件变化后自动热加载
+	if err := handler.GeoIP().Load(); err != nil {
+		log.Fatalf("Failed to load GeoIP databases: %v", err)
+	}
+	go handler.GeoIP().StartReload(ctx, cfg.Server.GeoIPReloadInterval)
+
+	// 启动流量统计批量写入任务（announce 增量 -> MongoDB）
+	accountingCtx, stopAccounting := context.WithCancel(ctx)
+	accountingDone := make(chan struct{})
+	go func() {
+		handler.Accountant().StartFlush(accountingCtx, cfg.Server.AccountingFlushInterval)
+		close(accountingDone)
+	}()
+
+	// 启动后台清理任务（每 CLEANUP_INTERVAL 增量扫描一次，多实例时由 Redis 租约协调）
+	go handler.StartCleanup(ctx, cfg.Server.CleanupInterval)
+
+	// 内存 Peer 存储：定期回收过期的 Peer 集合、身份、会话和限流计数
+	if store, ok := db.Peers.(*database.MemoryStore); ok {
+		go store.StartSweeper(ctx, time.Minute)
+	}
+
+	// 启动负载采样（Redis 延迟、announce 速率），负载升高时自适应拉长 interval
+	go handler.StartLoadMonitor(ctx)
+
+	// 启动 UDP Tracker（BEP-0015，UDP_PORT > 0 时启用），与 HTTP 共用同一个 Swarm
+	var udpServer *tracker.UDPServer
+	if cfg.Server.UDPPort > 0 {
+		udpServer, err = tracker.NewUDPServer(handler)
+		if err != nil {
+			log.Fatalf("Failed to create UDP tracker: %v", err)
+		}
+		// 先同步绑定套接字再启动处理循环，关闭时不会与 goroutine 竞争
+		udpAddr := fmt.Sprintf(":%d", cfg.Server.UDPPort)
+		if err := udpServer.Listen(udpAddr); err != nil {
+			log.Fatalf("Failed to start UDP tracker: %v", err)
+		}
+		fmt.Printf("📡 UDP Tracker listening on %s\n", udpAddr)
+		go func() {
+			if err := udpServer.Serve(ctx); err != nil {
+				log.Fatalf("UDP server failed: %v", err)
+			}
+		}()
+	}
 
 	// 设置路由
 	mux := http.NewServeMux()
 	mux.HandleFunc("/announce", handler.Announce)
+	mux.HandleFunc("/announce/{passkey}", handler.Announce)
+	mux.HandleFunc("/scrape", handler.Scrape)
+	mux.HandleFunc("/scrape/{passkey}", handler.Scrape)
+	mux.HandleFunc("/stats/clients", handler.ClientStats)
+	mux.HandleFunc("/stats/geo", handler.GeoStats)
+	mux.HandleFunc("/stats/cleanup", handler.CleanupStats)
 	mux.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
 		w.Write([]byte("OK"))
@@ -81,6 +136,14 @@ func main() {
 		log.Printf("Server forced to shutdown: %v", err)
 	}
 
+	if udpServer != nil {
+		udpServer.Close()
+	}
+
+	// 写入尚未落库的流量增量
+	stopAccounting()
+	<-accountingDone
+
 	fmt.Println("✅ Server stopped gracefully")
 }
 
@@ -90,7 +153,7 @@ func loggingMiddleware(next http.Handler) http.Handler {
 		start := time.Now()
 
 		// 记录请求
-		log.Printf("%s %s from %s", r.Method, r.URL.Path, r.RemoteAddr)
+		log.Printf("%s %s from %s", r.Method, redactPasskey(r.URL.Path), r.RemoteAddr)
 
 		// 调用下一个处理器
 		next.ServeHTTP(w, r)
@@ -100,3 +163,13 @@ func loggingMiddleware(next http.Handler) http.Handler {
 		log.Printf("Request completed in %v", duration)
 	})
 }
+
+// redactPasskey 隐藏日志中 /announce/{passkey}、/scrape/{passkey} 的 passkey
+func redactPasskey(path string) string {
+	for _, prefix := range []string{"/announce/", "/scrape/"} {
+		if strings.HasPrefix(path, prefix) && len(path) > len(prefix) {
+			return prefix + "***"
+		}
+	}
+	return path
+}
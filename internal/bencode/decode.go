@@ -0,0 +1,416 @@
+package bencode
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// Bencode 解码器 - 实现 BitTorrent BEP-0003 标准
+// 规范: https://www.bittorrent.org/beps/bep_0003.html
+//
+// 解码结果的 Go 类型：
+//   整数 -> int64
+//   字符串 -> string（二进制安全）
+//   列表 -> []interface{}
+//   字典 -> map[string]interface{}
+//
+// 严格校验规范形式（canonical form），拒绝以下输入：
+//   - 整数前导零（i03e）、负零（i-0e）、空整数（ie）
+//   - 字符串长度前导零（03:abc）
+//   - 字典键未按字节序严格递增（乱序或重复）
+//   - 顶层值之后存在多余数据
+
+const (
+	// DefaultMaxDepth 默认最大嵌套深度（列表/字典）
+	DefaultMaxDepth = 64
+	// DefaultMaxSize 默认最大输入大小（16MB，足以容纳大模型的 .torrent 文件）
+	DefaultMaxSize = 16 << 20
+)
+
+// DecodeOptions 解码限制，用于抵御恶意输入
+// 字段为 0 时使用默认值
+type DecodeOptions struct {
+	MaxDepth int // 最大嵌套深度
+	MaxSize  int // 最大输入字节数
+}
+
+// SyntaxError 解码错误，Offset 为出错位置在输入中的字节偏移
+type SyntaxError struct {
+	Offset int
+	Msg    string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("bencode: %s at offset %d", e.Msg, e.Offset)
+}
+
+// Decode 使用默认限制解码完整的 Bencode 数据
+func Decode(data []byte) (interface{}, error) {
+	return DecodeWithOptions(data, DecodeOptions{})
+}
+
+// DecodeWithOptions 使用指定限制解码完整的 Bencode 数据
+func DecodeWithOptions(data []byte, opts DecodeOptions) (interface{}, error) {
+	d, err := newDecoder(data, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	v, err := d.value()
+	if err != nil {
+		return nil, err
+	}
+	if err := d.finish(); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// RawValue 沿字典键路径查找子值，返回其在 data 中的原始字节（不复制）
+// 例如 RawValue(torrent, "info") 返回 info 字典的原始编码，可直接用于计算 info_hash
+// 路径为空时校验并返回整个 data
+func RawValue(data []byte, path ...string) ([]byte, error) {
+	d, err := newDecoder(data, DecodeOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	// 先整体校验，保证返回的子片段来自合法输入
+	if err := d.skip(); err != nil {
+		return nil, err
+	}
+	if err := d.finish(); err != nil {
+		return nil, err
+	}
+
+	start, end := 0, len(data)
+	for _, key := range path {
+		d.pos, d.depth = start, 0
+		if d.peek() != 'd' {
+			return nil, d.errorf(start, "value at %q is not a dict", key)
+		}
+		d.pos++
+
+		found := false
+		for d.peek() != 'e' {
+			k, err := d.readString()
+			if err != nil {
+				return nil, err
+			}
+			valueStart := d.pos
+			if err := d.skip(); err != nil {
+				return nil, err
+			}
+			if k == key {
+				start, end = valueStart, d.pos
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("bencode: key %q not found", key)
+		}
+	}
+
+	return data[start:end], nil
+}
+
+// decoder 基于游标的底层解码器，供 Decode/RawValue/Unmarshal 共用
+type decoder struct {
+	data     []byte
+	pos      int
+	depth    int
+	maxDepth int
+}
+
+func newDecoder(data []byte, opts DecodeOptions) (*decoder, error) {
+	maxDepth := opts.MaxDepth
+	if maxDepth <= 0 {
+		maxDepth = DefaultMaxDepth
+	}
+	maxSize := opts.MaxSize
+	if maxSize <= 0 {
+		maxSize = DefaultMaxSize
+	}
+
+	if len(data) > maxSize {
+		return nil, &SyntaxError{Offset: maxSize, Msg: fmt.Sprintf("input size %d exceeds limit %d", len(data), maxSize)}
+	}
+	if len(data) == 0 {
+		return nil, &SyntaxError{Offset: 0, Msg: "empty input"}
+	}
+
+	return &decoder{data: data, maxDepth: maxDepth}, nil
+}
+
+func (d *decoder) errorf(offset int, format string, args ...interface{}) error {
+	return &SyntaxError{Offset: offset, Msg: fmt.Sprintf(format, args...)}
+}
+
+// peek 返回当前字节，输入结束时返回 0
+func (d *decoder) peek() byte {
+	if d.pos >= len(d.data) {
+		return 0
+	}
+	return d.data[d.pos]
+}
+
+// finish 确认顶层值之后没有多余数据
+func (d *decoder) finish() error {
+	if d.pos != len(d.data) {
+		return d.errorf(d.pos, "trailing data after top-level value")
+	}
+	return nil
+}
+
+// value 解码当前位置的任意值
+func (d *decoder) value() (interface{}, error) {
+	switch c := d.peek(); {
+	case c == 'i':
+		return d.readInt()
+	case c >= '0' && c <= '9':
+		return d.readString()
+	case c == 'l':
+		return d.readList()
+	case c == 'd':
+		return d.readDict()
+	case c == 0:
+		return nil, d.errorf(d.pos, "unexpected end of input")
+	default:
+		return nil, d.errorf(d.pos, "invalid value prefix %q", c)
+	}
+}
+
+// skip 校验并跳过当前位置的任意值（不构造 Go 对象）
+func (d *decoder) skip() error {
+	switch c := d.peek(); {
+	case c == 'i':
+		_, err := d.readInt()
+		return err
+	case c >= '0' && c <= '9':
+		_, err := d.readBytes()
+		return err
+	case c == 'l':
+		if err := d.enter(); err != nil {
+			return err
+		}
+		for d.peek() != 'e' {
+			if err := d.skip(); err != nil {
+				return err
+			}
+		}
+		d.leave()
+		return nil
+	case c == 'd':
+		if err := d.enter(); err != nil {
+			return err
+		}
+		var prevKey []byte
+		for d.peek() != 'e' {
+			key, err := d.readKey(prevKey)
+			if err != nil {
+				return err
+			}
+			prevKey = key
+			if err := d.skip(); err != nil {
+				return err
+			}
+		}
+		d.leave()
+		return nil
+	case c == 0:
+		return d.errorf(d.pos, "unexpected end of input")
+	default:
+		return d.errorf(d.pos, "invalid value prefix %q", c)
+	}
+}
+
+// enter 进入列表或字典（消费 'l'/'d'），并检查嵌套深度
+func (d *decoder) enter() error {
+	d.depth++
+	if d.depth > d.maxDepth {
+		return d.errorf(d.pos, "nesting depth exceeds limit %d", d.maxDepth)
+	}
+	d.pos++
+	return nil
+}
+
+// leave 离开列表或字典（消费 'e'）
+func (d *decoder) leave() {
+	d.depth--
+	d.pos++
+}
+
+// readInt 解码整数: i<数字>e
+func (d *decoder) readInt() (int64, error) {
+	start := d.pos
+	if d.peek() != 'i' {
+		return 0, d.errorf(start, "expected integer")
+	}
+	d.pos++
+
+	end := bytes.IndexByte(d.data[d.pos:], 'e')
+	if end == -1 {
+		return 0, d.errorf(start, "unterminated integer")
+	}
+	digits := d.data[d.pos : d.pos+end]
+
+	n, err := parseCanonicalInt(digits)
+	if err != nil {
+		return 0, d.errorf(d.pos, "invalid integer: %v", err)
+	}
+
+	d.pos += end + 1
+	return n, nil
+}
+
+// readBytes 解码字符串: <长度>:<内容>，返回的切片引用输入数据
+func (d *decoder) readBytes() ([]byte, error) {
+	start := d.pos
+	colon := bytes.IndexByte(d.data[d.pos:], ':')
+	if colon == -1 {
+		return nil, d.errorf(start, "missing ':' in string length")
+	}
+	lengthDigits := d.data[d.pos : d.pos+colon]
+	if len(lengthDigits) > 1 && lengthDigits[0] == '0' {
+		return nil, d.errorf(start, "string length has leading zero")
+	}
+
+	length, err := parseCanonicalInt(lengthDigits)
+	if err != nil || length < 0 {
+		return nil, d.errorf(start, "invalid string length")
+	}
+
+	d.pos += colon + 1
+	if length > int64(len(d.data)-d.pos) {
+		return nil, d.errorf(start, "string length %d exceeds remaining input", length)
+	}
+
+	b := d.data[d.pos : d.pos+int(length)]
+	d.pos += int(length)
+	return b, nil
+}
+
+// readString 解码字符串并复制为 Go string
+func (d *decoder) readString() (string, error) {
+	b, err := d.readBytes()
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+// readKey 解码字典键，并校验其严格大于上一个键（排序且无重复）
+func (d *decoder) readKey(prevKey []byte) ([]byte, error) {
+	start := d.pos
+	if c := d.peek(); c < '0' || c > '9' {
+		if c == 0 {
+			return nil, d.errorf(start, "unterminated dict")
+		}
+		return nil, d.errorf(start, "dict key must be a string")
+	}
+
+	key, err := d.readBytes()
+	if err != nil {
+		return nil, err
+	}
+	if prevKey != nil && bytes.Compare(prevKey, key) >= 0 {
+		return nil, d.errorf(start, "dict key %q is not sorted or duplicated", key)
+	}
+	return key, nil
+}
+
+// readList 解码列表: l<元素>e
+func (d *decoder) readList() ([]interface{}, error) {
+	if err := d.enter(); err != nil {
+		return nil, err
+	}
+
+	list := make([]interface{}, 0)
+	for {
+		if c := d.peek(); c == 'e' {
+			break
+		} else if c == 0 {
+			return nil, d.errorf(d.pos, "unterminated list")
+		}
+		v, err := d.value()
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, v)
+	}
+
+	d.leave()
+	return list, nil
+}
+
+// readDict 解码字典: d<key><value>e
+func (d *decoder) readDict() (map[string]interface{}, error) {
+	if err := d.enter(); err != nil {
+		return nil, err
+	}
+
+	dict := make(map[string]interface{})
+	var prevKey []byte
+	for d.peek() != 'e' {
+		key, err := d.readKey(prevKey)
+		if err != nil {
+			return nil, err
+		}
+		prevKey = key
+
+		v, err := d.value()
+		if err != nil {
+			return nil, err
+		}
+		dict[string(key)] = v
+	}
+
+	d.leave()
+	return dict, nil
+}
+
+// parseCanonicalInt 按规范形式解析十进制整数
+// 拒绝空串、前导零、"-0"、"+" 号以及溢出
+func parseCanonicalInt(b []byte) (int64, error) {
+	if len(b) == 0 {
+		return 0, fmt.Errorf("empty")
+	}
+
+	neg := false
+	digits := b
+	if b[0] == '-' {
+		neg = true
+		digits = b[1:]
+		if len(digits) == 0 {
+			return 0, fmt.Errorf("missing digits")
+		}
+		if digits[0] == '0' {
+			return 0, fmt.Errorf("negative zero or leading zero")
+		}
+	}
+	if len(digits) > 1 && digits[0] == '0' {
+		return 0, fmt.Errorf("leading zero")
+	}
+
+	// 以负数累加，以便正确处理 math.MinInt64
+	var n int64
+	for _, c := range digits {
+		if c < '0' || c > '9' {
+			return 0, fmt.Errorf("invalid digit %q", c)
+		}
+		digit := int64(c - '0')
+		if n < (-1<<63+digit)/10 {
+			return 0, fmt.Errorf("overflow")
+		}
+		n = n*10 - digit
+	}
+
+	if !neg {
+		if n == -1<<63 {
+			return 0, fmt.Errorf("overflow")
+		}
+		n = -n
+	}
+	return n, nil
+}
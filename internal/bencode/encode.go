@@ -0,0 +1,188 @@
+package bencode
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+)
+
+// Marshal 将 Go 值编码为 Bencode
+//
+// 类型映射：
+//
+//	整数 / bool         -> 整数（bool 编码为 0 或 1）
+//	string / []byte     -> 字符串
+//	[N]byte             -> 字符串（适合 20 字节 info_hash / peer_id）
+//	切片 / 数组          -> 列表
+//	map[string]T        -> 字典（键自动排序）
+//	结构体               -> 字典，字段名取自 `bencode:"name,omitempty"` 标签
+//	RawMessage          -> 原样写出
+//
+// 结构体字段规则：
+//   - 无标签时使用字段名；标签为 "-" 时跳过
+//   - omitempty 时零值字段（0、""、空切片/map、nil 指针）不输出
+//   - 匿名嵌入的结构体字段会展开到外层字典中，外层同名字段优先
+func Marshal(v interface{}) ([]byte, error) {
+	return appendValue(nil, reflect.ValueOf(v))
+}
+
+// RawMessage 已编码的原始 Bencode 数据
+// 编码时原样写出；解码时保存该值的原始字节（如保留 info 字典用于计算 info_hash）
+type RawMessage []byte
+
+var rawMessageType = reflect.TypeOf(RawMessage(nil))
+
+// appendValue 将 v 的 Bencode 编码追加到 buf
+func appendValue(buf []byte, v reflect.Value) ([]byte, error) {
+	if !v.IsValid() {
+		return nil, fmt.Errorf("bencode: cannot marshal nil value")
+	}
+
+	if v.Type() == rawMessageType {
+		if v.Len() == 0 {
+			return nil, fmt.Errorf("bencode: cannot marshal empty RawMessage")
+		}
+		return append(buf, v.Bytes()...), nil
+	}
+
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			return nil, fmt.Errorf("bencode: cannot marshal nil %s", v.Type())
+		}
+		return appendValue(buf, v.Elem())
+
+	case reflect.Bool:
+		if v.Bool() {
+			return append(buf, "i1e"...), nil
+		}
+		return append(buf, "i0e"...), nil
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		buf = append(buf, 'i')
+		buf = strconv.AppendInt(buf, v.Int(), 10)
+		return append(buf, 'e'), nil
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		buf = append(buf, 'i')
+		buf = strconv.AppendUint(buf, v.Uint(), 10)
+		return append(buf, 'e'), nil
+
+	case reflect.String:
+		return appendString(buf, v.String()), nil
+
+	case reflect.Slice:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			return appendBytes(buf, v.Bytes()), nil
+		}
+		return appendList(buf, v)
+
+	case reflect.Array:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			b := make([]byte, v.Len())
+			reflect.Copy(reflect.ValueOf(b), v)
+			return appendBytes(buf, b), nil
+		}
+		return appendList(buf, v)
+
+	case reflect.Map:
+		return appendMap(buf, v)
+
+	case reflect.Struct:
+		return appendStruct(buf, v)
+
+	default:
+		return nil, fmt.Errorf("bencode: unsupported type %s", v.Type())
+	}
+}
+
+// appendString 追加字符串: <长度>:<内容>
+func appendString(buf []byte, s string) []byte {
+	buf = strconv.AppendInt(buf, int64(len(s)), 10)
+	buf = append(buf, ':')
+	return append(buf, s...)
+}
+
+// appendBytes 追加字节数组: <长度>:<内容>
+func appendBytes(buf []byte, b []byte) []byte {
+	buf = strconv.AppendInt(buf, int64(len(b)), 10)
+	buf = append(buf, ':')
+	return append(buf, b...)
+}
+
+// appendList 追加列表: l<元素>e
+func appendList(buf []byte, v reflect.Value) ([]byte, error) {
+	buf = append(buf, 'l')
+	for i := 0; i < v.Len(); i++ {
+		var err error
+		buf, err = appendValue(buf, v.Index(i))
+		if err != nil {
+			return nil, err
+		}
+	}
+	return append(buf, 'e'), nil
+}
+
+// appendMap 追加字典，键按字节序排序
+func appendMap(buf []byte, v reflect.Value) ([]byte, error) {
+	if v.Type().Key().Kind() != reflect.String {
+		return nil, fmt.Errorf("bencode: map key must be string, got %s", v.Type().Key())
+	}
+
+	keys := v.MapKeys()
+	sort.Slice(keys, func(i, j int) bool { return keys[i].String() < keys[j].String() })
+
+	buf = append(buf, 'd')
+	for _, key := range keys {
+		buf = appendString(buf, key.String())
+		var err error
+		buf, err = appendValue(buf, v.MapIndex(key))
+		if err != nil {
+			return nil, fmt.Errorf("bencode: key %q: %w", key.String(), err)
+		}
+	}
+	return append(buf, 'e'), nil
+}
+
+// appendStruct 追加结构体（按字段名排序的字典）
+func appendStruct(buf []byte, v reflect.Value) ([]byte, error) {
+	buf = append(buf, 'd')
+	for _, f := range cachedFields(v.Type()) {
+		fv, ok := fieldByIndex(v, f.index, false)
+		if !ok {
+			continue // 嵌入的 nil 指针
+		}
+		if f.omitEmpty && isEmptyValue(fv) {
+			continue
+		}
+		if (fv.Kind() == reflect.Ptr || fv.Kind() == reflect.Interface) && fv.IsNil() {
+			continue // nil 无法用 Bencode 表示，直接省略
+		}
+
+		buf = appendString(buf, f.name)
+		var err error
+		buf, err = appendValue(buf, fv)
+		if err != nil {
+			return nil, fmt.Errorf("bencode: field %q: %w", f.name, err)
+		}
+	}
+	return append(buf, 'e'), nil
+}
+
+// isEmptyValue 判断 omitempty 意义下的零值
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.String, reflect.Slice, reflect.Map, reflect.Array:
+		return v.Len() == 0
+	case reflect.Bool:
+		return !v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return v.Uint() == 0
+	case reflect.Ptr, reflect.Interface:
+		return v.IsNil()
+	}
+	return false
+}
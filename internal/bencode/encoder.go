@@ -0,0 +1,151 @@
+package bencode
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+)
+
+// Encoder 流式 Bencode 编码器
+//
+// 所有写入先追加到内部可复用缓冲区：
+//   - 创建时传入 io.Writer：缓冲区超过阈值时自动刷出，最后调用 Flush
+//   - 传入 nil：只写缓冲区，通过 Bytes 取结果（适合需要先设置 Content-Length 的 HTTP 响应）
+//
+// 调用 Reset 后可重复使用（配合 sync.Pool），热路径上不产生分配
+// 字典键的顺序由调用方保证，可使用 SortedKeys 预先计算并校验
+type Encoder struct {
+	w   io.Writer
+	buf []byte
+	err error
+}
+
+// encoderFlushThreshold 流式模式下缓冲区超过该大小即刷出
+const encoderFlushThreshold = 4096
+
+// Key 预编码的字典键（已包含长度前缀，如 "8:interval"）
+type Key []byte
+
+// NewKey 预编码单个字典键
+func NewKey(s string) Key {
+	return Key(appendString(nil, s))
+}
+
+// SortedKeys 预编码一组字典键，并校验它们已按字节序严格递增
+// 键顺序错误属于编程错误，直接 panic（通常在包初始化时调用）
+func SortedKeys(keys ...string) []Key {
+	out := make([]Key, len(keys))
+	for i, k := range keys {
+		if i > 0 && keys[i-1] >= k {
+			panic(fmt.Sprintf("bencode: keys not sorted: %q >= %q", keys[i-1], k))
+		}
+		out[i] = NewKey(k)
+	}
+	return out
+}
+
+// NewEncoder 创建编码器，w 为 nil 时只写入内部缓冲区
+func NewEncoder(w io.Writer) *Encoder {
+	return &Encoder{w: w, buf: make([]byte, 0, 512)}
+}
+
+// Reset 清空缓冲区与错误状态并切换输出目标，保留已分配的容量
+func (e *Encoder) Reset(w io.Writer) {
+	e.w = w
+	e.buf = e.buf[:0]
+	e.err = nil
+}
+
+// Bytes 返回缓冲区中尚未刷出的数据（在下次写入或 Reset 前有效）
+func (e *Encoder) Bytes() []byte {
+	return e.buf
+}
+
+// Len 返回缓冲区中尚未刷出的字节数
+func (e *Encoder) Len() int {
+	return len(e.buf)
+}
+
+// Err 返回编码或写出过程中遇到的第一个错误
+func (e *Encoder) Err() error {
+	return e.err
+}
+
+// Flush 将缓冲区写入底层 io.Writer
+func (e *Encoder) Flush() error {
+	if e.err != nil {
+		return e.err
+	}
+	if e.w == nil || len(e.buf) == 0 {
+		return nil
+	}
+	if _, err := e.w.Write(e.buf); err != nil {
+		e.err = err
+		return err
+	}
+	e.buf = e.buf[:0]
+	return nil
+}
+
+// maybeFlush 流式模式下缓冲区过大时刷出
+func (e *Encoder) maybeFlush() {
+	if e.w != nil && len(e.buf) >= encoderFlushThreshold {
+		e.Flush()
+	}
+}
+
+// WriteInt 写入整数: i<数字>e
+func (e *Encoder) WriteInt(n int64) {
+	e.buf = append(e.buf, 'i')
+	e.buf = strconv.AppendInt(e.buf, n, 10)
+	e.buf = append(e.buf, 'e')
+	e.maybeFlush()
+}
+
+// WriteString 写入字符串: <长度>:<内容>
+func (e *Encoder) WriteString(s string) {
+	e.buf = appendString(e.buf, s)
+	e.maybeFlush()
+}
+
+// WriteBytes 写入字节数组: <长度>:<内容>
+func (e *Encoder) WriteBytes(b []byte) {
+	e.buf = appendBytes(e.buf, b)
+	e.maybeFlush()
+}
+
+// WriteKey 写入预编码的字典键
+func (e *Encoder) WriteKey(k Key) {
+	e.buf = append(e.buf, k...)
+}
+
+// BeginList 开始列表
+func (e *Encoder) BeginList() {
+	e.buf = append(e.buf, 'l')
+}
+
+// BeginDict 开始字典
+func (e *Encoder) BeginDict() {
+	e.buf = append(e.buf, 'd')
+}
+
+// End 结束当前列表或字典
+func (e *Encoder) End() {
+	e.buf = append(e.buf, 'e')
+	e.maybeFlush()
+}
+
+// Encode 通过反射写入任意值（规则同 Marshal）
+func (e *Encoder) Encode(v interface{}) error {
+	if e.err != nil {
+		return e.err
+	}
+	buf, err := appendValue(e.buf, reflect.ValueOf(v))
+	if err != nil {
+		return err
+	}
+	e.buf = buf
+	e.maybeFlush()
+	return e.err
+}
@@ -0,0 +1,108 @@
+package bencode
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// field 结构体字段的编码信息
+type field struct {
+	name      string // 字典键
+	index     []int  // reflect 字段索引路径（嵌入字段时长度大于 1）
+	omitEmpty bool
+	depth     int // 嵌入深度，同名时浅层优先
+}
+
+// 按类型缓存字段列表，避免每次编解码都重新反射
+var fieldCache sync.Map // map[reflect.Type][]field
+
+// cachedFields 返回结构体的可编码字段，已按字典键排序
+func cachedFields(t reflect.Type) []field {
+	if f, ok := fieldCache.Load(t); ok {
+		return f.([]field)
+	}
+	f, _ := fieldCache.LoadOrStore(t, typeFields(t))
+	return f.([]field)
+}
+
+// typeFields 解析结构体字段及其 bencode 标签，展开匿名嵌入结构体
+func typeFields(t reflect.Type) []field {
+	byName := make(map[string]field)
+	collectFields(t, nil, 0, byName, map[reflect.Type]bool{})
+
+	fields := make([]field, 0, len(byName))
+	for _, f := range byName {
+		fields = append(fields, f)
+	}
+	sort.Slice(fields, func(i, j int) bool { return fields[i].name < fields[j].name })
+	return fields
+}
+
+// collectFields 递归收集字段；同名字段保留嵌入深度最浅、声明最早的一个
+func collectFields(t reflect.Type, index []int, depth int, byName map[string]field, visited map[reflect.Type]bool) {
+	if visited[t] {
+		return
+	}
+	visited[t] = true
+	defer delete(visited, t)
+
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		tag := sf.Tag.Get("bencode")
+		if tag == "-" {
+			continue
+		}
+
+		name, opts, _ := strings.Cut(tag, ",")
+		fieldIndex := append(append([]int(nil), index...), i)
+
+		// 无标签名的匿名结构体字段：展开到外层
+		if sf.Anonymous && name == "" {
+			ft := sf.Type
+			if ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				collectFields(ft, fieldIndex, depth+1, byName, visited)
+				continue
+			}
+		}
+
+		if !sf.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = sf.Name
+		}
+
+		if existing, ok := byName[name]; ok && existing.depth <= depth {
+			continue
+		}
+		byName[name] = field{
+			name:      name,
+			index:     fieldIndex,
+			omitEmpty: opts == "omitempty",
+			depth:     depth,
+		}
+	}
+}
+
+// fieldByIndex 按索引路径取字段值
+// 路径上遇到 nil 的嵌入指针时：alloc 为 true 则分配新对象，否则返回 false
+func fieldByIndex(v reflect.Value, index []int, alloc bool) (reflect.Value, bool) {
+	for i, idx := range index {
+		if i > 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				if !alloc {
+					return reflect.Value{}, false
+				}
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(idx)
+	}
+	return v, true
+}
@@ -0,0 +1,270 @@
+package bencode
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// Unmarshal 将 Bencode 数据解码到 v 指向的值中（v 必须为非 nil 指针）
+// 类型映射与 Marshal 对称；字典中结构体没有的键会被忽略，
+// 解码到 interface{} 时得到与 Decode 相同的通用类型
+func Unmarshal(data []byte, v interface{}) error {
+	return UnmarshalWithOptions(data, v, DecodeOptions{})
+}
+
+// UnmarshalWithOptions 使用指定解码限制执行 Unmarshal
+func UnmarshalWithOptions(data []byte, v interface{}, opts DecodeOptions) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return fmt.Errorf("bencode: Unmarshal requires a non-nil pointer, got %T", v)
+	}
+
+	d, err := newDecoder(data, opts)
+	if err != nil {
+		return err
+	}
+	if err := d.unmarshal(rv.Elem()); err != nil {
+		return err
+	}
+	return d.finish()
+}
+
+// unmarshal 将当前位置的值解码到 v
+func (d *decoder) unmarshal(v reflect.Value) error {
+	start := d.pos
+
+	if v.Type() == rawMessageType {
+		if err := d.skip(); err != nil {
+			return err
+		}
+		v.SetBytes(append([]byte(nil), d.data[start:d.pos]...))
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		return d.unmarshal(v.Elem())
+
+	case reflect.Interface:
+		if v.NumMethod() != 0 {
+			return d.errorf(start, "cannot unmarshal into non-empty interface %s", v.Type())
+		}
+		generic, err := d.value()
+		if err != nil {
+			return err
+		}
+		v.Set(reflect.ValueOf(generic))
+		return nil
+
+	case reflect.Bool:
+		n, err := d.readInt()
+		if err != nil {
+			return err
+		}
+		v.SetBool(n != 0)
+		return nil
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := d.readInt()
+		if err != nil {
+			return err
+		}
+		if v.OverflowInt(n) {
+			return d.errorf(start, "integer %d overflows %s", n, v.Type())
+		}
+		v.SetInt(n)
+		return nil
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		n, err := d.readInt()
+		if err != nil {
+			return err
+		}
+		if n < 0 || v.OverflowUint(uint64(n)) {
+			return d.errorf(start, "integer %d overflows %s", n, v.Type())
+		}
+		v.SetUint(uint64(n))
+		return nil
+
+	case reflect.String:
+		s, err := d.readString()
+		if err != nil {
+			return err
+		}
+		v.SetString(s)
+		return nil
+
+	case reflect.Slice:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			b, err := d.readBytes()
+			if err != nil {
+				return err
+			}
+			v.SetBytes(append([]byte(nil), b...))
+			return nil
+		}
+		return d.unmarshalList(v)
+
+	case reflect.Array:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			b, err := d.readBytes()
+			if err != nil {
+				return err
+			}
+			if len(b) != v.Len() {
+				return d.errorf(start, "string length %d does not match %s", len(b), v.Type())
+			}
+			reflect.Copy(v, reflect.ValueOf(b))
+			return nil
+		}
+		return d.unmarshalList(v)
+
+	case reflect.Map:
+		return d.unmarshalMap(v)
+
+	case reflect.Struct:
+		return d.unmarshalStruct(v)
+
+	default:
+		return d.errorf(start, "cannot unmarshal into unsupported type %s", v.Type())
+	}
+}
+
+// unmarshalList 解码列表到切片或数组
+func (d *decoder) unmarshalList(v reflect.Value) error {
+	if d.peek() != 'l' {
+		return d.errorf(d.pos, "expected list for %s", v.Type())
+	}
+	if err := d.enter(); err != nil {
+		return err
+	}
+
+	isArray := v.Kind() == reflect.Array
+	if !isArray {
+		v.Set(reflect.MakeSlice(v.Type(), 0, 0))
+	}
+
+	i := 0
+	for {
+		if c := d.peek(); c == 'e' {
+			break
+		} else if c == 0 {
+			return d.errorf(d.pos, "unterminated list")
+		}
+
+		if isArray {
+			if i >= v.Len() {
+				return d.errorf(d.pos, "too many elements for %s", v.Type())
+			}
+			if err := d.unmarshal(v.Index(i)); err != nil {
+				return err
+			}
+		} else {
+			elem := reflect.New(v.Type().Elem()).Elem()
+			if err := d.unmarshal(elem); err != nil {
+				return err
+			}
+			v.Set(reflect.Append(v, elem))
+		}
+		i++
+	}
+
+	if isArray && i != v.Len() {
+		return d.errorf(d.pos, "too few elements for %s", v.Type())
+	}
+
+	d.leave()
+	return nil
+}
+
+// unmarshalMap 解码字典到 map[string]T
+func (d *decoder) unmarshalMap(v reflect.Value) error {
+	if v.Type().Key().Kind() != reflect.String {
+		return d.errorf(d.pos, "map key must be string, got %s", v.Type().Key())
+	}
+	if d.peek() != 'd' {
+		return d.errorf(d.pos, "expected dict for %s", v.Type())
+	}
+	if err := d.enter(); err != nil {
+		return err
+	}
+
+	if v.IsNil() {
+		v.Set(reflect.MakeMap(v.Type()))
+	}
+
+	var prevKey []byte
+	for d.peek() != 'e' {
+		key, err := d.readKey(prevKey)
+		if err != nil {
+			return err
+		}
+		prevKey = key
+
+		elem := reflect.New(v.Type().Elem()).Elem()
+		if err := d.unmarshal(elem); err != nil {
+			return err
+		}
+		v.SetMapIndex(reflect.ValueOf(string(key)).Convert(v.Type().Key()), elem)
+	}
+
+	d.leave()
+	return nil
+}
+
+// unmarshalStruct 解码字典到结构体，未知键跳过
+func (d *decoder) unmarshalStruct(v reflect.Value) error {
+	if d.peek() != 'd' {
+		return d.errorf(d.pos, "expected dict for %s", v.Type())
+	}
+	if err := d.enter(); err != nil {
+		return err
+	}
+
+	fields := cachedFields(v.Type())
+
+	var prevKey []byte
+	for d.peek() != 'e' {
+		key, err := d.readKey(prevKey)
+		if err != nil {
+			return err
+		}
+		prevKey = key
+
+		f := lookupField(fields, string(key))
+		if f == nil {
+			if err := d.skip(); err != nil {
+				return err
+			}
+			continue
+		}
+
+		fv, _ := fieldByIndex(v, f.index, true)
+		if err := d.unmarshal(fv); err != nil {
+			return err
+		}
+	}
+
+	d.leave()
+	return nil
+}
+
+// lookupField 在已排序的字段列表中查找字典键对应的字段
+func lookupField(fields []field, name string) *field {
+	lo, hi := 0, len(fields)
+	for lo < hi {
+		mid := (lo + hi) / 2
+		switch {
+		case fields[mid].name == name:
+			return &fields[mid]
+		case fields[mid].name < name:
+			lo = mid + 1
+		default:
+			hi = mid
+		}
+	}
+	return nil
+}
@@ -2,11 +2,20 @@ package config
 
 import (
 	"fmt"
+	"net/netip"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 )
 
+// announce 请求中 ip= 参数的接受策略
+const (
+	IPParamAllow   = "allow"   // 接受任意合法 IP
+	IPParamDeny    = "deny"    // 一律忽略
+	IPParamPrivate = "private" // 仅接受私有 / 回环 / 链路本地地址（局域网内 NAT 场景）
+)
+
 // Config 应用配置
 type Config struct {
 	MongoDB MongoDBConfig
@@ -25,25 +34,86 @@ type MongoDBConfig struct {
 	MaxConnIdleTime time.Duration // 连接最大空闲时间，默认 30s
 }
 
+// Redis 部署模式（REDIS_MODE）
+const (
+	RedisModeStandalone = "standalone" // 单机
+	RedisModeSentinel   = "sentinel"   // 哨兵自动故障转移
+	RedisModeCluster    = "cluster"    // Redis Cluster
+)
+
 // RedisConfig Redis 配置
 type RedisConfig struct {
-	Host         string
-	Port         string
-	Password     string
-	DB           int
-	PoolSize     uint64 // 连接池最大连接数，默认 50
-	MinIdleConns uint64 // 连接池最小空闲连接数，默认 10
+	Mode             string // standalone / sentinel / cluster
+	Host             string
+	Port             string
+	Addrs            []string // 哨兵地址或集群种子节点，为空时使用 Host:Port
+	MasterName       string   // 哨兵模式下的主节点名称
+	Password         string
+	SentinelPassword string
+	DB               int
+	PoolSize         uint64 // 连接池最大连接数（集群模式下为每个节点），默认 50
+	MinIdleConns     uint64 // 连接池最小空闲连接数，默认 10
+}
+
+// Peer 存储后端（PEER_STORE）
+const (
+	PeerStoreRedis  = "redis"  // Redis，多个 Tracker 实例共享 Swarm
+	PeerStoreMemory = "memory" // 进程内存储，单机部署 / 测试用，不需要 Redis
+)
+
+// 内置的 Peer 选取策略名称（PEER_SELECTOR，或种子的 peer_selector 字段）
+const (
+	PeerSelectorRandom        = "random"         // 随机混合 30% 做种者 + 70% 下载者
+	PeerSelectorScarceSeeders = "scarce-seeders" // 下载者不足时才返回做种者
+	PeerSelectorFresh         = "fresh"          // 优先返回心跳最新的 Peer
+	PeerSelectorLocality      = "locality"       // 优先返回同网段 / 同站点的 Peer
+)
+
+// Site 运维定义的站点（数据中心）及其网段，用于 locality 策略就近返回 Peer
+type Site struct {
+	Name     string
+	Prefixes []netip.Prefix
 }
 
 // ServerConfig 服务器配置
 type ServerConfig struct {
-	Port                int
-	TrackerURL          string
-	Environment         string
-	AnnounceInterval    time.Duration
-	AnnounceMinInterval time.Duration
-	RateLimitWindow     time.Duration
-	RateLimitBurst      int
+	Port                    int
+	UDPPort                 int // UDP Tracker 端口（BEP-0015），默认 0 不启用（新增公网监听需显式开启）
+	TrackerURL              string
+	Environment             string
+	PeerStore               string        // Peer 存储后端：redis / memory
+	AnnounceInterval        time.Duration // 基准心跳间隔，自适应间隔以此为中心按 Swarm 规模和负载伸缩
+	AnnounceMinInterval     time.Duration
+	AdaptiveInterval        bool          // 是否按 Swarm 规模和服务器负载自适应调整 interval
+	AnnounceIntervalFloor   time.Duration // 自适应 interval 下限
+	AnnounceIntervalCeiling time.Duration // 自适应 interval 上限
+	AnnounceIntervalJitter  int           // interval 随机抖动幅度（百分比），打散重启后的集中 announce
+	LoadLatencyTarget       time.Duration // Redis 往返延迟超过该值时拉长 interval
+	LoadRateTarget          int           // 每秒 announce 数超过该值时拉长 interval，0 表示不按请求速率调整
+	RateLimitWindow         time.Duration
+	RateLimitBurst          int
+	CleanupInterval         time.Duration  // 过期 Peer 清理任务的运行间隔
+	CleanupBudget           time.Duration  // 每次清理最多耗时，未扫完的部分下次继续
+	ScrapeMaxHashes         int            // 单次 /scrape 最多允许的 info_hash 数量，0 表示不限制
+	ScrapeAllowFull         bool           // 是否允许不带 info_hash 的全量 scrape（仍需管理员令牌）
+	AdminToken              string         // 管理员令牌（X-Admin-Token），为空时禁用所有管理员功能
+	StatsSampleTorrents     int            // /stats/clients、/stats/geo 每次最多统计的种子数，活跃种子更多时随机抽样
+	StatsCacheTTL           time.Duration  // /stats/clients、/stats/geo 结果的缓存时间，0 表示不缓存
+	RegistrySyncInterval    time.Duration  // 从 MongoDB 刷新种子注册表（混合种子关联等）的间隔
+	WhitelistMode           bool           // 白名单模式：只接受 MongoDB 中已登记种子的 announce / scrape
+	RequirePasskey          bool           // 私有 Tracker 模式：必须使用 /announce/{passkey} 个人 URL
+	PasskeyCacheTTL         time.Duration  // passkey 查询结果在 Redis 中的缓存时间
+	AccountingFlushInterval time.Duration  // 上传/下载增量批量写入 MongoDB 的间隔
+	TrustedProxies          []netip.Prefix // 受信任的反向代理网段，只有来自这些地址的 X-Forwarded-For / X-Real-IP 才会被采信
+	IPParamPolicy           string         // ip= 参数策略：allow / deny / private
+	DualStackAnnounce       bool           // 是否登记 ipv4= / ipv6= 提供的另一地址族地址（BEP-0007，不受 IPParamPolicy 约束）
+	ClientAllowlist         []string       // 允许的客户端 peer_id 前缀（如 -qB、-TR），为空表示不限制
+	ClientDenylist          []string       // 禁止的客户端 peer_id 前缀，优先于白名单
+	PeerSelector            string         // 默认 Peer 选取策略，可被种子的 peer_selector 字段覆盖
+	Sites                   []Site         // 站点网段分组（locality 策略）
+	LocalityRandomPercent   int            // locality 策略至少保留给随机 Peer 的名额比例（0~100），避免 Swarm 按站点割裂
+	GeoIPDatabases          []string       // 本地 mmdb 国家 / ASN 数据库文件路径，为空表示不启用
+	GeoIPReloadInterval     time.Duration  // 检查 mmdb 文件变化并热加载的间隔
 }
 
 // Load 加载配置（从环境变量）
@@ -59,27 +129,133 @@ func Load() (*Config, error) {
 			MaxConnIdleTime: getEnvDuration("MONGODB_MAX_CONN_IDLE_TIME", 30*time.Second),
 		},
 		Redis: RedisConfig{
-			Host:         getEnv("REDIS_HOST", "localhost"),
-			Port:         getEnv("REDIS_PORT", "6379"),
-			Password:     getEnv("REDIS_PASSWORD", ""),
-			DB:           0,
-			PoolSize:     getEnvUint64("REDIS_POOL_SIZE", 50),
-			MinIdleConns: getEnvUint64("REDIS_MIN_IDLE_CONNS", 10),
+			Mode:             getEnv("REDIS_MODE", RedisModeStandalone),
+			Host:             getEnv("REDIS_HOST", "localhost"),
+			Port:             getEnv("REDIS_PORT", "6379"),
+			Addrs:            getEnvList("REDIS_ADDRS"),
+			MasterName:       getEnv("REDIS_MASTER_NAME", ""),
+			Password:         getEnv("REDIS_PASSWORD", ""),
+			SentinelPassword: getEnv("REDIS_SENTINEL_PASSWORD", ""),
+			DB:               0,
+			PoolSize:         getEnvUint64("REDIS_POOL_SIZE", 50),
+			MinIdleConns:     getEnvUint64("REDIS_MIN_IDLE_CONNS", 10),
 		},
 		Server: ServerConfig{
-			Port:                getEnvInt("SERVER_PORT", 8080),
-			TrackerURL:          getEnv("TRACKER_URL", "http://localhost:8080/announce"),
-			Environment:         getEnv("ENVIRONMENT", "development"),
-			AnnounceInterval:    getEnvDuration("ANNOUNCE_INTERVAL", 1800*time.Second),
-			AnnounceMinInterval: getEnvDuration("ANNOUNCE_MIN_INTERVAL", 900*time.Second),
-			RateLimitWindow:     getEnvDuration("RATE_LIMIT_WINDOW", 15*time.Minute),
-			RateLimitBurst:      getEnvInt("RATE_LIMIT_BURST", 30),
+			Port:                    getEnvInt("SERVER_PORT", 8080),
+			UDPPort:                 getEnvInt("UDP_PORT", 0),
+			TrackerURL:              getEnv("TRACKER_URL", "http://localhost:8080/announce"),
+			Environment:             getEnv("ENVIRONMENT", "development"),
+			PeerStore:               getEnv("PEER_STORE", PeerStoreRedis),
+			AnnounceInterval:        getEnvDuration("ANNOUNCE_INTERVAL", 1800*time.Second),
+			AnnounceMinInterval:     getEnvDuration("ANNOUNCE_MIN_INTERVAL", 900*time.Second),
+			AdaptiveInterval:        getEnvBool("ADAPTIVE_INTERVAL", true),
+			AnnounceIntervalFloor:   getEnvDuration("ANNOUNCE_INTERVAL_FLOOR", 300*time.Second),
+			AnnounceIntervalCeiling: getEnvDuration("ANNOUNCE_INTERVAL_CEILING", 3600*time.Second),
+			AnnounceIntervalJitter:  getEnvInt("ANNOUNCE_INTERVAL_JITTER", 10),
+			LoadLatencyTarget:       getEnvDuration("LOAD_LATENCY_TARGET", 20*time.Millisecond),
+			LoadRateTarget:          getEnvInt("LOAD_RATE_TARGET", 2000),
+			RateLimitWindow:         getEnvDuration("RATE_LIMIT_WINDOW", 15*time.Minute),
+			RateLimitBurst:          getEnvInt("RATE_LIMIT_BURST", 30),
+			CleanupInterval:         getEnvDuration("CLEANUP_INTERVAL", time.Minute),
+			CleanupBudget:           getEnvDuration("CLEANUP_BUDGET", 5*time.Second),
+			ScrapeMaxHashes:         getEnvInt("SCRAPE_MAX_HASHES", 74),
+			ScrapeAllowFull:         getEnvBool("SCRAPE_ALLOW_FULL", false),
+			AdminToken:              getEnv("ADMIN_TOKEN", ""),
+			StatsSampleTorrents:     getEnvInt("STATS_SAMPLE_TORRENTS", 500),
+			StatsCacheTTL:           getEnvDuration("STATS_CACHE_TTL", 30*time.Second),
+			RegistrySyncInterval:    getEnvDuration("REGISTRY_SYNC_INTERVAL", 60*time.Second),
+			WhitelistMode:           getEnvBool("WHITELIST_MODE", false),
+			RequirePasskey:          getEnvBool("REQUIRE_PASSKEY", false),
+			PasskeyCacheTTL:         getEnvDuration("PASSKEY_CACHE_TTL", 5*time.Minute),
+			AccountingFlushInterval: getEnvDuration("ACCOUNTING_FLUSH_INTERVAL", 30*time.Second),
+			IPParamPolicy:           getEnv("IP_PARAM_POLICY", IPParamPrivate),
+			DualStackAnnounce:       getEnvBool("DUAL_STACK_ANNOUNCE", true),
+			ClientAllowlist:         getEnvList("CLIENT_ALLOWLIST"),
+			ClientDenylist:          getEnvList("CLIENT_DENYLIST"),
+			PeerSelector:            getEnv("PEER_SELECTOR", PeerSelectorRandom),
+			LocalityRandomPercent:   getEnvInt("LOCALITY_RANDOM_PERCENT", 20),
+			GeoIPDatabases:          getEnvList("GEOIP_DATABASES"),
+			GeoIPReloadInterval:     getEnvDuration("GEOIP_RELOAD_INTERVAL", time.Minute),
 		},
 	}
 
+	trustedProxies, err := getEnvPrefixes("TRUSTED_PROXIES")
+	if err != nil {
+		return nil, err
+	}
+	config.Server.TrustedProxies = trustedProxies
+
+	sites, err := getEnvSites("SITE_CIDRS")
+	if err != nil {
+		return nil, err
+	}
+	config.Server.Sites = sites
+
+	switch config.Server.IPParamPolicy {
+	case IPParamAllow, IPParamDeny, IPParamPrivate:
+	default:
+		return nil, fmt.Errorf("invalid IP_PARAM_POLICY: %q (expected allow, deny or private)", config.Server.IPParamPolicy)
+	}
+
+	switch config.Server.PeerStore {
+	case PeerStoreRedis, PeerStoreMemory:
+	default:
+		return nil, fmt.Errorf("invalid PEER_STORE: %q (expected redis or memory)", config.Server.PeerStore)
+	}
+
+	switch config.Redis.Mode {
+	case RedisModeStandalone, RedisModeCluster:
+	case RedisModeSentinel:
+		if config.Redis.MasterName == "" {
+			return nil, fmt.Errorf("REDIS_MASTER_NAME is required when REDIS_MODE=sentinel")
+		}
+	default:
+		return nil, fmt.Errorf("invalid REDIS_MODE: %q (expected standalone, sentinel or cluster)", config.Redis.Mode)
+	}
+
+	switch config.Server.PeerSelector {
+	case PeerSelectorRandom, PeerSelectorScarceSeeders, PeerSelectorFresh, PeerSelectorLocality:
+	default:
+		return nil, fmt.Errorf("invalid PEER_SELECTOR: %q", config.Server.PeerSelector)
+	}
+
+	if config.Server.AnnounceIntervalFloor <= 0 || config.Server.AnnounceIntervalFloor > config.Server.AnnounceIntervalCeiling {
+		return nil, fmt.Errorf("invalid announce interval bounds: ANNOUNCE_INTERVAL_FLOOR=%s ANNOUNCE_INTERVAL_CEILING=%s",
+			config.Server.AnnounceIntervalFloor, config.Server.AnnounceIntervalCeiling)
+	}
+	if j := config.Server.AnnounceIntervalJitter; j < 0 || j > 50 {
+		return nil, fmt.Errorf("invalid ANNOUNCE_INTERVAL_JITTER: %d (expected 0-50)", j)
+	}
+
+	if config.Server.CleanupInterval <= 0 || config.Server.CleanupBudget <= 0 || config.Server.CleanupBudget >= config.Server.CleanupInterval {
+		return nil, fmt.Errorf("invalid cleanup schedule: CLEANUP_BUDGET=%s must be positive and less than CLEANUP_INTERVAL=%s",
+			config.Server.CleanupBudget, config.Server.CleanupInterval)
+	}
+
+	if config.Server.StatsSampleTorrents <= 0 {
+		return nil, fmt.Errorf("invalid STATS_SAMPLE_TORRENTS: %d (must be positive)", config.Server.StatsSampleTorrents)
+	}
+	if config.Server.StatsCacheTTL < 0 {
+		return nil, fmt.Errorf("invalid STATS_CACHE_TTL: %s (must not be negative)", config.Server.StatsCacheTTL)
+	}
+
+	if p := config.Server.LocalityRandomPercent; p < 0 || p > 100 {
+		return nil, fmt.Errorf("invalid LOCALITY_RANDOM_PERCENT: %d (expected 0-100)", p)
+	}
+
 	return config, nil
 }
 
+// AnnounceIntervalBounds 返回可能下发的 announce interval 范围
+// 开启自适应时为 [FLOOR, CEILING]，否则为 ANNOUNCE_INTERVAL 加减抖动幅度
+func (s *ServerConfig) AnnounceIntervalBounds() (lo, hi time.Duration) {
+	if s.AdaptiveInterval {
+		return s.AnnounceIntervalFloor, s.AnnounceIntervalCeiling
+	}
+	jitter := s.AnnounceInterval * time.Duration(s.AnnounceIntervalJitter) / 100
+	return s.AnnounceInterval - jitter, s.AnnounceInterval + jitter
+}
+
 // GetMongoURI 获取 MongoDB 连接字符串
 func (c *Config) GetMongoURI() string {
 	if c.MongoDB.URI != "" {
@@ -89,6 +265,14 @@ func (c *Config) GetMongoURI() string {
 		c.MongoDB.Username, c.MongoDB.Password)
 }
 
+// GetRedisAddrs 获取 Redis 地址列表：REDIS_ADDRS，未配置时为 REDIS_HOST:REDIS_PORT
+func (c *Config) GetRedisAddrs() []string {
+	if len(c.Redis.Addrs) > 0 {
+		return c.Redis.Addrs
+	}
+	return []string{c.GetRedisAddr()}
+}
+
 // GetRedisAddr 获取 Redis 地址
 func (c *Config) GetRedisAddr() string {
 	return fmt.Sprintf("%s:%s", c.Redis.Host, c.Redis.Port)
@@ -129,6 +313,19 @@ func getEnvInt(key string, defaultValue int) int {
 	return v
 }
 
+// getEnvBool 获取环境变量并解析为 bool（如 "true", "1"）
+func getEnvBool(key string, defaultValue bool) bool {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		return defaultValue
+	}
+	return b
+}
+
 // getEnvDuration 获取环境变量并解析为 time.Duration（如 "30s", "1m"）
 func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
 	value := os.Getenv(key)
@@ -141,3 +338,74 @@ func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
 	}
 	return d
 }
+
+// getEnvList 获取以逗号分隔的字符串列表（忽略空项）
+func getEnvList(key string) []string {
+	var list []string
+	for _, item := range strings.Split(os.Getenv(key), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+// getEnvPrefixes 获取以逗号分隔的 CIDR 列表（单个 IP 视为 /32 或 /128）
+func getEnvPrefixes(key string) ([]netip.Prefix, error) {
+	return parsePrefixes(key, os.Getenv(key))
+}
+
+// parsePrefixes 解析逗号分隔的 CIDR 或单个 IP 列表
+func parsePrefixes(key, value string) ([]netip.Prefix, error) {
+	if value == "" {
+		return nil, nil
+	}
+
+	var prefixes []netip.Prefix
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		if !strings.Contains(item, "/") {
+			addr, err := netip.ParseAddr(item)
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s entry %q: %w", key, item, err)
+			}
+			prefixes = append(prefixes, netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()))
+			continue
+		}
+		prefix, err := netip.ParsePrefix(item)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s entry %q: %w", key, item, err)
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+	return prefixes, nil
+}
+
+// getEnvSites 解析站点分组：站点之间用分号分隔，每个站点为 "名称=CIDR,CIDR,..."
+// 例如 "dc-a=10.1.0.0/16,2001:db8:a::/48;dc-b=10.2.0.0/16"
+func getEnvSites(key string) ([]Site, error) {
+	var sites []Site
+	for _, item := range strings.Split(os.Getenv(key), ";") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		name, cidrs, ok := strings.Cut(item, "=")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" {
+			return nil, fmt.Errorf("invalid %s entry %q: expected name=cidr[,cidr...]", key, item)
+		}
+		prefixes, err := parsePrefixes(key, cidrs)
+		if err != nil {
+			return nil, err
+		}
+		if len(prefixes) == 0 {
+			return nil, fmt.Errorf("invalid %s entry %q: site has no networks", key, item)
+		}
+		sites = append(sites, Site{Name: name, Prefixes: prefixes})
+	}
+	return sites, nil
+}
@@ -2,14 +2,21 @@ package database
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"fmt"
+	"strings"
+	"time"
+
 	"llmpt/internal/config"
+	"llmpt/internal/models"
 )
 
 // DB 数据库管理器
 type DB struct {
 	MongoDB *MongoDB
-	Redis   *Redis
+	Redis   *Redis // PEER_STORE=memory 时为 nil
+	Peers   PeerStore
 }
 
 // New 创建新的数据库连接管理器
@@ -25,19 +32,58 @@ func New(cfg *config.Config) (*DB, error) {
 		return nil, fmt.Errorf("failed to initialize MongoDB: %w", err)
 	}
 
-	// 连接 Redis
-	redisPoolOpts := &RedisPoolOptions{
-		PoolSize:     int(cfg.Redis.PoolSize),
-		MinIdleConns: int(cfg.Redis.MinIdleConns),
-	}
-	redisClient, err := NewRedis(cfg.GetRedisAddr(), cfg.Redis.Password, cfg.Redis.DB, redisPoolOpts)
-	if err != nil {
-		return nil, fmt.Errorf("failed to initialize Redis: %w", err)
-	}
+	db := &DB{MongoDB: mongodb}
+
+	if cfg.Server.PeerStore == config.PeerStoreMemory {
+		// 进程内存储，不连接 Redis
+		db.Peers = NewMemoryStore()
+		fmt.Println("✓ Using in-memory peer store (Redis disabled)")
+	} else {
+		// 连接 Redis
+		redisClient, err := NewRedis(&RedisOptions{
+			Mode:             cfg.Redis.Mode,
+			Addrs:            cfg.GetRedisAddrs(),
+			MasterName:       cfg.Redis.MasterName,
+			Password:         cfg.Redis.Password,
+			SentinelPassword: cfg.Redis.SentinelPassword,
+			DB:               cfg.Redis.DB,
+			PoolSize:         int(cfg.Redis.PoolSize),
+			MinIdleConns:     int(cfg.Redis.MinIdleConns),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to initialize Redis: %w", err)
+		}
+
+		// 旧版键（不带哈希标签）迁移到新键
+		migrated, err := redisClient.MigrateLegacyKeys(context.Background())
+		if err != nil {
+			return nil, fmt.Errorf("failed to migrate legacy Redis keys: %w", err)
+		}
+		if migrated > 0 {
+			fmt.Printf("✓ Migrated %d legacy Redis entries to hash-tagged keys\n", migrated)
+		}
 
-	db := &DB{
-		MongoDB: mongodb,
-		Redis:   redisClient,
+		// 旧版 "IP:Port" 文本 Peer 成员转换为 Compact 二进制格式
+		converted, err := redisClient.MigratePeerEncoding(context.Background())
+		if err != nil {
+			return nil, fmt.Errorf("failed to migrate peer encoding: %w", err)
+		}
+		if converted > 0 {
+			fmt.Printf("✓ Converted %d Redis peer entries to compact encoding\n", converted)
+		}
+
+		// 旧版以最近心跳时间为分数的 Peer 转换为以过期时间为分数，沿用旧版的死亡判定时长（2 倍心跳间隔上限）
+		_, hi := cfg.Server.AnnounceIntervalBounds()
+		rescored, err := redisClient.MigratePeerDeadlines(context.Background(), 2*hi)
+		if err != nil {
+			return nil, fmt.Errorf("failed to migrate peer scores: %w", err)
+		}
+		if rescored > 0 {
+			fmt.Printf("✓ Converted %d Redis peer scores to expiry deadlines\n", rescored)
+		}
+
+		db.Redis = redisClient
+		db.Peers = redisClient
 	}
 
 	// 创建索引
@@ -59,10 +105,107 @@ func (db *DB) Close() error {
 	}
 
 	// 关闭 Redis
-	if err := db.Redis.Close(); err != nil {
-		return fmt.Errorf("failed to close Redis: %w", err)
+	if db.Redis != nil {
+		if err := db.Redis.Close(); err != nil {
+			return fmt.Errorf("failed to close Redis: %w", err)
+		}
 	}
 
 	fmt.Println("✓ All database connections closed")
 	return nil
 }
+
+// GeneratePasskey 生成新的随机 passkey（32 字符 hex）
+func GeneratePasskey() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate passkey: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// CreateUser 创建私有 Tracker 用户并分配 passkey
+func (db *DB) CreateUser(ctx context.Context, name string) (*models.User, error) {
+	passkey, err := GeneratePasskey()
+	if err != nil {
+		return nil, err
+	}
+
+	user := &models.User{
+		Name:      name,
+		Passkey:   passkey,
+		CreatedAt: time.Now(),
+	}
+	if err := db.MongoDB.InsertUser(ctx, user); err != nil {
+		return nil, err
+	}
+
+	// 清除可能存在的负缓存
+	if err := db.invalidatePasskey(ctx, passkey); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// RevokePasskey 吊销 passkey，并立即清除 Redis 缓存使其失效
+func (db *DB) RevokePasskey(ctx context.Context, passkey string) error {
+	if err := db.MongoDB.RevokeUserPasskey(ctx, passkey); err != nil {
+		return err
+	}
+	return db.invalidatePasskey(ctx, passkey)
+}
+
+// invalidatePasskey 清除 passkey 缓存（未使用 Redis 时没有缓存）
+func (db *DB) invalidatePasskey(ctx context.Context, passkey string) error {
+	if db.Redis == nil {
+		return nil
+	}
+	if err := db.Redis.InvalidatePasskey(ctx, passkey); err != nil {
+		return fmt.Errorf("failed to invalidate passkey cache: %w", err)
+	}
+	return nil
+}
+
+// PublishTorrent 登记种子，并通知所有 Tracker 实例更新本地注册表（白名单、混合种子关联）
+func (db *DB) PublishTorrent(ctx context.Context, torrent *models.Torrent) error {
+	torrent.InfoHash = strings.ToLower(torrent.InfoHash)
+	torrent.InfoHashV2 = strings.ToLower(torrent.InfoHashV2)
+	if torrent.CreatedAt.IsZero() {
+		torrent.CreatedAt = time.Now()
+	}
+
+	if err := db.MongoDB.InsertTorrent(ctx, torrent); err != nil {
+		return err
+	}
+
+	return db.publishTorrentEvent(ctx, TorrentEvent{
+		Action:       TorrentEventPublish,
+		InfoHash:     torrent.InfoHash,
+		InfoHashV2:   torrent.InfoHashV2,
+		PeerSelector: torrent.PeerSelector,
+	})
+}
+
+// DeleteTorrent 删除种子，并通知所有 Tracker 实例将其移出本地注册表
+func (db *DB) DeleteTorrent(ctx context.Context, infoHash string) error {
+	torrent, err := db.MongoDB.DeleteTorrent(ctx, strings.ToLower(infoHash))
+	if err != nil {
+		return err
+	}
+	if torrent == nil {
+		return fmt.Errorf("torrent not found")
+	}
+
+	return db.publishTorrentEvent(ctx, TorrentEvent{Action: TorrentEventDelete, InfoHash: torrent.InfoHash, InfoHashV2: torrent.InfoHashV2})
+}
+
+// publishTorrentEvent 广播种子事件；未使用 Redis 时没有其他实例，本实例由注册表定期同步感知变化
+func (db *DB) publishTorrentEvent(ctx context.Context, event TorrentEvent) error {
+	if db.Redis == nil {
+		return nil
+	}
+	if err := db.Redis.PublishTorrentEvent(ctx, event); err != nil {
+		return fmt.Errorf("failed to publish torrent event: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,666 @@
+package database
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"maps"
+	"math/rand"
+	"slices"
+	"sync"
+	"time"
+
+	"llmpt/internal/models"
+)
+
+// MemoryStore 进程内的 PeerStore 实现（PEER_STORE=memory）
+//
+// 用于无需 Redis 的单机部署（实验环境）和测试，数据不跨实例共享，重启即丢失。
+// 按 info_hash 分成 memoryShardCount 个分片，每个分片一把读写锁，不同种子的 announce 互不阻塞；
+// 混合种子的两个哈希可能位于不同分片，逐个加锁读取，任何时候最多持有一把分片锁。
+// 过期语义与 Redis 版本一致：每个 Peer 在本次登记 ttl 后过期，做种者 / 下载者集合的过期时间只延长不缩短，
+// 身份记录与本次 Peer 同时过期；读取时即按集合过期时间判断，过期数据的内存由 StartSweeper 定期回收
+type MemoryStore struct {
+	shards [memoryShardCount]memoryShard
+
+	cleanMu   sync.Mutex
+	cleanNext int // CleanExpiredPeers 下次从该分片继续
+}
+
+// memoryShardCount 分片数量
+const memoryShardCount = 64
+
+// memoryShard 一个锁分片：info_hash 落在该分片的 Swarm、完成次数、身份与会话，以及 IP 落在该分片的限流计数
+type memoryShard struct {
+	mu         sync.RWMutex
+	swarms     map[string]*memorySwarm
+	completed  map[string]int64
+	identities map[memoryKey]*memoryIdentity
+	sessions   map[memoryKey]*memorySession
+	limits     map[string]*memoryRateLimit
+}
+
+// memoryKey 种子内的身份（peer_id）或会话标识
+type memoryKey struct {
+	infoHash string
+	id       string
+}
+
+// memorySwarm 单个 info_hash 的 Peer
+type memorySwarm struct {
+	seeders        peerSet
+	leechers       peerSet
+	seedersExpire  time.Time
+	leechersExpire time.Time
+	peerIDs        map[string]string // Peer 成员 -> peer_id
+}
+
+// memoryIdentity Peer 身份记录：登记时的 key 与地址列表
+type memoryIdentity struct {
+	key     string
+	addrs   []string
+	expires time.Time
+}
+
+// memorySession 流量统计会话：上次 announce 的上传 / 下载计数
+type memorySession struct {
+	uploaded   int64
+	downloaded int64
+	expires    time.Time
+}
+
+// memoryRateLimit 固定窗口限流计数
+type memoryRateLimit struct {
+	count   int
+	expires time.Time
+}
+
+// NewMemoryStore 创建进程内 Peer 存储
+func NewMemoryStore() *MemoryStore {
+	m := &MemoryStore{}
+	for i := range m.shards {
+		m.shards[i] = memoryShard{
+			swarms:     make(map[string]*memorySwarm),
+			completed:  make(map[string]int64),
+			identities: make(map[memoryKey]*memoryIdentity),
+			sessions:   make(map[memoryKey]*memorySession),
+			limits:     make(map[string]*memoryRateLimit),
+		}
+	}
+	return m
+}
+
+// shard 按 FNV-1a 哈希选择分片
+func (m *MemoryStore) shard(key string) *memoryShard {
+	h := uint32(2166136261)
+	for i := 0; i < len(key); i++ {
+		h ^= uint32(key[i])
+		h *= 16777619
+	}
+	return &m.shards[h%memoryShardCount]
+}
+
+// Ping 内存存储没有网络往返，延迟恒为 0
+func (m *MemoryStore) Ping(ctx context.Context) (time.Duration, error) {
+	return 0, nil
+}
+
+// Announce 依次执行限流、登记 / 移除、完成计数、选取与计数（进程内调用没有往返开销，不需要合并）
+func (m *MemoryStore) Announce(ctx context.Context, op *PeerAnnounce) (*PeerAnnounceResult, error) {
+	return announceSteps(ctx, m, op)
+}
+
+// AddPeer 登记 Peer 的全部地址，语义与 Redis.AddPeer 相同
+func (m *MemoryStore) AddPeer(ctx context.Context, infoHash string, peers []string, peerID, key string, isSeeder bool, ttl time.Duration) error {
+	now := time.Now()
+	shard := m.shard(infoHash)
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	id := memoryKey{infoHash, peerID}
+	prev, err := shard.identity(id, key, now)
+	if err != nil {
+		return err
+	}
+
+	swarm := shard.swarms[infoHash]
+	if swarm == nil {
+		swarm = &memorySwarm{peerIDs: make(map[string]string)}
+		shard.swarms[infoHash] = swarm
+	}
+	// 与 Redis 键过期一致：已过期但尚未回收的集合先清空，再重新登记
+	swarm.expire(now)
+
+	// IP / 端口变化后移除旧地址，不留下幽灵 Peer
+	if prev != nil {
+		for _, addr := range prev.addrs {
+			if !slices.Contains(peers, addr) {
+				swarm.remove(addr)
+			}
+		}
+	}
+
+	add, rem := &swarm.leechers, &swarm.seeders
+	expires := &swarm.leechersExpire
+	if isSeeder {
+		add, rem = &swarm.seeders, &swarm.leechers
+		expires = &swarm.seedersExpire
+	}
+	deadline := now.Add(ttl)
+	for _, peer := range peers {
+		add.add(peer, deadline.UnixNano())
+		rem.remove(peer)
+		swarm.peerIDs[peer] = peerID
+	}
+	if expires.Before(deadline) {
+		*expires = deadline
+	}
+
+	// 身份记录与 Peer 同时过期，期间换 IP 仍能替换旧地址
+	shard.identities[id] = &memoryIdentity{key: key, addrs: slices.Clone(peers), expires: deadline}
+	return nil
+}
+
+// RemovePeer 移除 Peer 登记过的全部地址以及 peers，key 不一致时返回 ErrPeerKeyMismatch
+func (m *MemoryStore) RemovePeer(ctx context.Context, infoHash string, peers []string, peerID, key string) error {
+	now := time.Now()
+	shard := m.shard(infoHash)
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	id := memoryKey{infoHash, peerID}
+	prev, err := shard.identity(id, key, now)
+	if err != nil {
+		return err
+	}
+	delete(shard.identities, id)
+
+	swarm := shard.swarms[infoHash]
+	if swarm == nil {
+		return nil
+	}
+	if prev != nil {
+		for _, addr := range prev.addrs {
+			swarm.remove(addr)
+		}
+	}
+	for _, peer := range peers {
+		swarm.remove(peer)
+	}
+	if swarm.empty(now) {
+		delete(shard.swarms, infoHash)
+	}
+	return nil
+}
+
+// identity 查询未过期的身份记录，已登记的 key 与本次不一致（包括空 key 变为非空）时返回 ErrPeerKeyMismatch（调用方持有写锁）
+func (s *memoryShard) identity(id memoryKey, key string, now time.Time) (*memoryIdentity, error) {
+	prev := s.identities[id]
+	if prev == nil || !now.Before(prev.expires) {
+		return nil, nil
+	}
+	if prev.key != key {
+		return nil, ErrPeerKeyMismatch
+	}
+	return prev, nil
+}
+
+// GetPeersForRequest 按比例混合做种者和下载者，策略与 Redis.GetPeersForRequest 相同
+func (m *MemoryStore) GetPeersForRequest(ctx context.Context, infoHash string, maxPeers int64, isSeeder bool, linked ...string) ([]string, error) {
+	return MixPeers(int(maxPeers), isSeeder, func(seeders bool, count int) ([]string, error) {
+		return m.RandomPeers(ctx, infoHash, seeders, count, linked...)
+	})
+}
+
+// RandomPeers 从做种者或下载者中随机取出最多 count 个 Peer
+func (m *MemoryStore) RandomPeers(ctx context.Context, infoHash string, seeders bool, count int, linked ...string) ([]string, error) {
+	if count <= 0 {
+		return nil, nil
+	}
+
+	now := time.Now()
+	lists := make([][]string, 0, 1+len(linked))
+	for _, hash := range append([]string{infoHash}, linked...) {
+		shard := m.shard(hash)
+		shard.mu.RLock()
+		if set := shard.role(hash, seeders, now); set != nil {
+			lists = append(lists, set.random(count))
+		}
+		shard.mu.RUnlock()
+	}
+
+	if len(lists) == 1 {
+		return lists[0], nil
+	}
+	return mergePeerLists(lists, count), nil
+}
+
+// FreshPeers 从做种者或下载者中取出过期时间最晚（通常即心跳最新）的最多 count 个 Peer
+func (m *MemoryStore) FreshPeers(ctx context.Context, infoHash string, seeders bool, count int, linked ...string) ([]string, error) {
+	if count <= 0 {
+		return nil, nil
+	}
+
+	now := time.Now()
+	var merged []peerEntry
+	for _, hash := range append([]string{infoHash}, linked...) {
+		shard := m.shard(hash)
+		shard.mu.RLock()
+		if set := shard.role(hash, seeders, now); set != nil {
+			merged = append(merged, set.freshest(count)...)
+		}
+		shard.mu.RUnlock()
+	}
+	slices.SortStableFunc(merged, func(a, b peerEntry) int { return cmp.Compare(b.deadline, a.deadline) })
+
+	seen := make(map[string]bool, len(merged))
+	peers := make([]string, 0, count)
+	for _, e := range merged {
+		if seen[e.addr] {
+			continue
+		}
+		seen[e.addr] = true
+		peers = append(peers, e.addr)
+		if len(peers) == count {
+			break
+		}
+	}
+	return peers, nil
+}
+
+// role 返回未过期的做种者或下载者集合，不存在时返回 nil（调用方持有读锁）
+func (s *memoryShard) role(infoHash string, seeders bool, now time.Time) *peerSet {
+	swarm := s.swarms[infoHash]
+	if swarm == nil {
+		return nil
+	}
+	return swarm.role(seeders, now)
+}
+
+// GetPeerIDs 批量查询 Peer 的 peer_id，返回 Peer 成员 -> peer_id
+func (m *MemoryStore) GetPeerIDs(ctx context.Context, infoHash string, peers []string, linked ...string) (map[string]string, error) {
+	result := make(map[string]string, len(peers))
+	if len(peers) == 0 {
+		return result, nil
+	}
+
+	for _, hash := range append([]string{infoHash}, linked...) {
+		shard := m.shard(hash)
+		shard.mu.RLock()
+		if swarm := shard.swarms[hash]; swarm != nil {
+			for _, peer := range peers {
+				if _, found := result[peer]; found {
+					continue
+				}
+				if id, ok := swarm.peerIDs[peer]; ok {
+					result[peer] = id
+				}
+			}
+		}
+		shard.mu.RUnlock()
+	}
+	return result, nil
+}
+
+// GetPeerCount 获取做种者和下载者数量，linked 的数量合计在内
+func (m *MemoryStore) GetPeerCount(ctx context.Context, infoHash string, linked ...string) (seeders, leechers int64, err error) {
+	now := time.Now()
+	for _, hash := range append([]string{infoHash}, linked...) {
+		shard := m.shard(hash)
+		shard.mu.RLock()
+		if swarm := shard.swarms[hash]; swarm != nil {
+			seeders += int64(swarm.role(true, now).len())
+			leechers += int64(swarm.role(false, now).len())
+		}
+		shard.mu.RUnlock()
+	}
+	return seeders, leechers, nil
+}
+
+// GetActiveTorrents 获取所有有 Peer 的种子
+func (m *MemoryStore) GetActiveTorrents(ctx context.Context) ([]string, error) {
+	var infoHashes []string
+	for i := range m.shards {
+		shard := &m.shards[i]
+		shard.mu.RLock()
+		for infoHash := range shard.swarms {
+			infoHashes = append(infoHashes, infoHash)
+		}
+		shard.mu.RUnlock()
+	}
+	return infoHashes, nil
+}
+
+// GetSwarmPeers 批量获取多个种子中全部 Peer 的地址与 peer_id
+func (m *MemoryStore) GetSwarmPeers(ctx context.Context, infoHashes []string) (map[string]map[string]string, error) {
+	result := make(map[string]map[string]string, len(infoHashes))
+	for _, infoHash := range infoHashes {
+		shard := m.shard(infoHash)
+		shard.mu.RLock()
+		peers := make(map[string]string)
+		if swarm := shard.swarms[infoHash]; swarm != nil {
+			maps.Copy(peers, swarm.peerIDs)
+		}
+		shard.mu.RUnlock()
+		result[infoHash] = peers
+	}
+	return result, nil
+}
+
+// IncrementCompleted 增加完成下载的计数
+func (m *MemoryStore) IncrementCompleted(ctx context.Context, infoHash string) error {
+	shard := m.shard(infoHash)
+	shard.mu.Lock()
+	shard.completed[infoHash]++
+	shard.mu.Unlock()
+	return nil
+}
+
+// GetCompleted 获取完成下载的计数
+func (m *MemoryStore) GetCompleted(ctx context.Context, infoHash string) (int64, error) {
+	shard := m.shard(infoHash)
+	shard.mu.RLock()
+	defer shard.mu.RUnlock()
+	return shard.completed[infoHash], nil
+}
+
+// GetTorrentStats 批量获取多个种子的做种 / 下载人数与完成次数，linked 的数量合计在内
+func (m *MemoryStore) GetTorrentStats(ctx context.Context, targets []StatsTarget) ([]models.TorrentStats, error) {
+	now := time.Now()
+	stats := make([]models.TorrentStats, len(targets))
+	for i, target := range targets {
+		for _, hash := range append([]string{target.InfoHash}, target.Linked...) {
+			shard := m.shard(hash)
+			shard.mu.RLock()
+			if swarm := shard.swarms[hash]; swarm != nil {
+				stats[i].Seeders += int64(swarm.role(true, now).len())
+				stats[i].Leechers += int64(swarm.role(false, now).len())
+			}
+			stats[i].Completed += shard.completed[hash]
+			shard.mu.RUnlock()
+		}
+	}
+	return stats, nil
+}
+
+// CleanExpiredPeers 按分片增量移除已过期的 Peer，并删除空 Swarm
+// 单进程存储不需要租约，sweep.Lease 被忽略
+func (m *MemoryStore) CleanExpiredPeers(ctx context.Context, sweep *PeerSweep) (*SweepResult, error) {
+	m.cleanMu.Lock()
+	defer m.cleanMu.Unlock()
+
+	now := time.Now()
+	result := &SweepResult{}
+
+	for !result.Wrapped {
+		shard := &m.shards[m.cleanNext]
+		shard.mu.Lock()
+		for infoHash, swarm := range shard.swarms {
+			result.Scanned++
+			for _, set := range []*peerSet{&swarm.seeders, &swarm.leechers} {
+				for _, addr := range set.removeBefore(now.UnixNano()) {
+					delete(swarm.peerIDs, addr)
+					result.PeersReaped++
+				}
+			}
+			if swarm.empty(now) {
+				delete(shard.swarms, infoHash)
+				result.TorrentsReaped++
+			}
+		}
+		shard.mu.Unlock()
+
+		m.cleanNext = (m.cleanNext + 1) % memoryShardCount
+		result.Wrapped = m.cleanNext == 0
+		if sweep.Budget > 0 && time.Since(now) >= sweep.Budget {
+			break
+		}
+	}
+	return result, nil
+}
+
+// CheckRateLimit 检查指定 IP 的请求频率是否超过限制（固定窗口），返回 false 表示限流
+func (m *MemoryStore) CheckRateLimit(ctx context.Context, ip string, window time.Duration, limit int) (bool, error) {
+	now := time.Now()
+	shard := m.shard(ip)
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	counter := shard.limits[ip]
+	if counter == nil || !now.Before(counter.expires) {
+		counter = &memoryRateLimit{expires: now.Add(window)}
+		shard.limits[ip] = counter
+	}
+	counter.count++
+	return counter.count <= limit, nil
+}
+
+// UpdatePeerSession 更新 Peer 会话并返回自上次 announce 以来的上传/下载增量，规则与 Redis.UpdatePeerSession 相同
+func (m *MemoryStore) UpdatePeerSession(ctx context.Context, infoHash, identity string, uploaded, downloaded int64, started, stopped bool, ttl time.Duration) (deltaUp, deltaDown int64, err error) {
+	now := time.Now()
+	shard := m.shard(infoHash)
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	id := memoryKey{infoHash, identity}
+	prev := shard.sessions[id]
+	switch {
+	case started:
+		deltaUp, deltaDown = uploaded, downloaded
+	case prev != nil && now.Before(prev.expires):
+		deltaUp, deltaDown = counterDelta(uploaded, prev.uploaded), counterDelta(downloaded, prev.downloaded)
+	}
+
+	if stopped {
+		delete(shard.sessions, id)
+	} else {
+		shard.sessions[id] = &memorySession{uploaded: uploaded, downloaded: downloaded, expires: now.Add(ttl)}
+	}
+	return deltaUp, deltaDown, nil
+}
+
+// counterDelta 计数器增量，当前值小于上次值视为客户端重启导致计数器归零
+func counterDelta(current, prev int64) int64 {
+	if current >= prev {
+		return current - prev
+	}
+	return current
+}
+
+// StartSweeper 定期回收已过期的 Peer 集合、身份记录、会话和限流计数，直到 ctx 取消
+// 超时 Peer 的判定仍由 Tracker 的清理任务通过 CleanExpiredPeers 完成
+func (m *MemoryStore) StartSweeper(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if n := m.sweep(time.Now()); n > 0 {
+				fmt.Printf("[memory] swept %d expired entries\n", n)
+			}
+		}
+	}
+}
+
+// sweep 删除 now 之前过期的数据，返回删除的条目数
+func (m *MemoryStore) sweep(now time.Time) int {
+	swept := 0
+	for i := range m.shards {
+		shard := &m.shards[i]
+		shard.mu.Lock()
+		for infoHash, swarm := range shard.swarms {
+			swept += swarm.expire(now)
+			if swarm.empty(now) {
+				delete(shard.swarms, infoHash)
+			}
+		}
+		for id, identity := range shard.identities {
+			if !now.Before(identity.expires) {
+				delete(shard.identities, id)
+				swept++
+			}
+		}
+		for id, session := range shard.sessions {
+			if !now.Before(session.expires) {
+				delete(shard.sessions, id)
+				swept++
+			}
+		}
+		for ip, counter := range shard.limits {
+			if !now.Before(counter.expires) {
+				delete(shard.limits, ip)
+				swept++
+			}
+		}
+		shard.mu.Unlock()
+	}
+	return swept
+}
+
+// role 返回未过期的做种者或下载者集合，已过期时返回 nil
+func (s *memorySwarm) role(seeders bool, now time.Time) *peerSet {
+	set, expires := &s.leechers, s.leechersExpire
+	if seeders {
+		set, expires = &s.seeders, s.seedersExpire
+	}
+	if !now.Before(expires) {
+		return nil
+	}
+	return set
+}
+
+// expire 清空已过期的做种者 / 下载者集合，返回移除的 Peer 数
+func (s *memorySwarm) expire(now time.Time) int {
+	removed := 0
+	for _, seeders := range []bool{true, false} {
+		set, expires := &s.leechers, s.leechersExpire
+		if seeders {
+			set, expires = &s.seeders, s.seedersExpire
+		}
+		if set.len() == 0 || now.Before(expires) {
+			continue
+		}
+		for _, addr := range set.addrs {
+			delete(s.peerIDs, addr)
+		}
+		removed += set.len()
+		*set = peerSet{}
+	}
+	return removed
+}
+
+// remove 从两个集合中移除地址
+func (s *memorySwarm) remove(addr string) {
+	s.seeders.remove(addr)
+	s.leechers.remove(addr)
+	delete(s.peerIDs, addr)
+}
+
+// empty 是否已没有未过期的 Peer
+func (s *memorySwarm) empty(now time.Time) bool {
+	return s.role(true, now).len() == 0 && s.role(false, now).len() == 0
+}
+
+// peerSet 一组 Peer 地址及其过期时间，支持 O(1) 增删与随机取样
+type peerSet struct {
+	addrs     []string
+	deadlines []int64        // 与 addrs 一一对应的过期时间（UnixNano）
+	index     map[string]int // 地址 -> 在 addrs 中的下标
+}
+
+// peerEntry 带过期时间的 Peer 地址
+type peerEntry struct {
+	addr     string
+	deadline int64
+}
+
+// len 集合大小，nil 集合为 0
+func (s *peerSet) len() int {
+	if s == nil {
+		return 0
+	}
+	return len(s.addrs)
+}
+
+// add 加入地址或刷新其过期时间
+func (s *peerSet) add(addr string, deadline int64) {
+	if i, ok := s.index[addr]; ok {
+		s.deadlines[i] = deadline
+		return
+	}
+	if s.index == nil {
+		s.index = make(map[string]int)
+	}
+	s.index[addr] = len(s.addrs)
+	s.addrs = append(s.addrs, addr)
+	s.deadlines = append(s.deadlines, deadline)
+}
+
+// remove 移除地址（与末尾元素交换后截断）
+func (s *peerSet) remove(addr string) {
+	i, ok := s.index[addr]
+	if !ok {
+		return
+	}
+	last := len(s.addrs) - 1
+	if i != last {
+		s.addrs[i], s.deadlines[i] = s.addrs[last], s.deadlines[last]
+		s.index[s.addrs[i]] = i
+	}
+	s.addrs, s.deadlines = s.addrs[:last], s.deadlines[:last]
+	delete(s.index, addr)
+}
+
+// removeBefore 移除过期时间早于 now 的地址，返回被移除的地址
+func (s *peerSet) removeBefore(now int64) []string {
+	var removed []string
+	for i := len(s.addrs) - 1; i >= 0; i-- {
+		if s.deadlines[i] < now {
+			removed = append(removed, s.addrs[i])
+		}
+	}
+	for _, addr := range removed {
+		s.remove(addr)
+	}
+	return removed
+}
+
+// random 随机取出最多 count 个不重复的地址（Floyd 采样，只访问 count 个元素）
+func (s *peerSet) random(count int) []string {
+	n := len(s.addrs)
+	if count >= n {
+		peers := slices.Clone(s.addrs)
+		rand.Shuffle(len(peers), func(i, j int) { peers[i], peers[j] = peers[j], peers[i] })
+		return peers
+	}
+
+	picked := make(map[int]bool, count)
+	peers := make([]string, 0, count)
+	for j := n - count; j < n; j++ {
+		t := rand.Intn(j + 1)
+		if picked[t] {
+			t = j
+		}
+		picked[t] = true
+		peers = append(peers, s.addrs[t])
+	}
+	// Floyd 采样得到的集合是均匀的，但顺序不是
+	rand.Shuffle(len(peers), func(i, j int) { peers[i], peers[j] = peers[j], peers[i] })
+	return peers
+}
+
+// freshest 取出过期时间最晚的最多 count 个地址
+func (s *peerSet) freshest(count int) []peerEntry {
+	entries := make([]peerEntry, len(s.addrs))
+	for i, addr := range s.addrs {
+		entries[i] = peerEntry{addr: addr, deadline: s.deadlines[i]}
+	}
+	slices.SortFunc(entries, func(a, b peerEntry) int { return cmp.Compare(b.deadline, a.deadline) })
+	if len(entries) > count {
+		entries = entries[:count]
+	}
+	return entries
+}
@@ -5,6 +5,10 @@ import (
 	"fmt"
 	"time"
 
+	"llmpt/internal/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 	"go.mongodb.org/mongo-driver/mongo/readpref"
@@ -100,6 +104,16 @@ func (m *MongoDB) TorrentsCollection() *mongo.Collection {
 	return m.GetCollection("torrents")
 }
 
+// UsersCollection 获取 users 集合
+func (m *MongoDB) UsersCollection() *mongo.Collection {
+	return m.GetCollection("users")
+}
+
+// TorrentTrafficCollection 获取 torrent_traffic 集合
+func (m *MongoDB) TorrentTrafficCollection() *mongo.Collection {
+	return m.GetCollection("torrent_traffic")
+}
+
 // CreateIndexes 创建索引
 func (m *MongoDB) CreateIndexes(ctx context.Context) error {
 	torrents := m.TorrentsCollection()
@@ -110,6 +124,12 @@ func (m *MongoDB) CreateIndexes(ctx context.Context) error {
 		Options: options.Index().SetUnique(true),
 	}
 
+	// 创建 info_hash_v2 唯一索引（仅 v2 / 混合种子有该字段）
+	infoHashV2Index := mongo.IndexModel{
+		Keys:    map[string]interface{}{"info_hash_v2": 1},
+		Options: options.Index().SetUnique(true).SetSparse(true),
+	}
+
 	// 创建 created_at 索引（用于排序）
 	createdAtIndex := mongo.IndexModel{
 		Keys: map[string]interface{}{"created_at": -1},
@@ -120,13 +140,158 @@ func (m *MongoDB) CreateIndexes(ctx context.Context) error {
 		Keys: map[string]interface{}{"name": "text"},
 	}
 
-	indexes := []mongo.IndexModel{infoHashIndex, createdAtIndex, nameIndex}
+	indexes := []mongo.IndexModel{infoHashIndex, infoHashV2Index, createdAtIndex, nameIndex}
 
 	_, err := torrents.Indexes().CreateMany(ctx, indexes)
 	if err != nil {
 		return fmt.Errorf("failed to create indexes: %w", err)
 	}
 
+	// 创建 users.passkey 唯一索引
+	passkeyIndex := mongo.IndexModel{
+		Keys:    map[string]interface{}{"passkey": 1},
+		Options: options.Index().SetUnique(true),
+	}
+	if _, err := m.UsersCollection().Indexes().CreateOne(ctx, passkeyIndex); err != nil {
+		return fmt.Errorf("failed to create user indexes: %w", err)
+	}
+
+	// 创建 torrent_traffic.info_hash 唯一索引
+	trafficIndex := mongo.IndexModel{
+		Keys:    map[string]interface{}{"info_hash": 1},
+		Options: options.Index().SetUnique(true),
+	}
+	if _, err := m.TorrentTrafficCollection().Indexes().CreateOne(ctx, trafficIndex); err != nil {
+		return fmt.Errorf("failed to create traffic indexes: %w", err)
+	}
+
 	fmt.Println("✓ MongoDB indexes created successfully")
 	return nil
 }
+
+// ListTorrentHashes 获取所有已登记种子的哈希及 Tracker 相关字段（info_hash、info_hash_v2、peer_selector），用于构建本地注册表
+func (m *MongoDB) ListTorrentHashes(ctx context.Context) ([]models.Torrent, error) {
+	opts := options.Find().SetProjection(bson.M{"info_hash": 1, "info_hash_v2": 1, "peer_selector": 1})
+
+	cursor, err := m.TorrentsCollection().Find(ctx, bson.M{}, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list torrents: %w", err)
+	}
+
+	var torrents []models.Torrent
+	if err := cursor.All(ctx, &torrents); err != nil {
+		return nil, fmt.Errorf("failed to decode torrents: %w", err)
+	}
+	return torrents, nil
+}
+
+// InsertTorrent 登记种子
+func (m *MongoDB) InsertTorrent(ctx context.Context, torrent *models.Torrent) error {
+	result, err := m.TorrentsCollection().InsertOne(ctx, torrent)
+	if err != nil {
+		return fmt.Errorf("failed to insert torrent: %w", err)
+	}
+	if id, ok := result.InsertedID.(primitive.ObjectID); ok {
+		torrent.ID = id
+	}
+	return nil
+}
+
+// DeleteTorrent 按 info_hash 删除种子，返回被删除的文档，不存在时返回 nil
+func (m *MongoDB) DeleteTorrent(ctx context.Context, infoHash string) (*models.Torrent, error) {
+	var torrent models.Torrent
+	err := m.TorrentsCollection().FindOneAndDelete(ctx, bson.M{"info_hash": infoHash}).Decode(&torrent)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to delete torrent: %w", err)
+	}
+	return &torrent, nil
+}
+
+// InsertUser 创建用户
+func (m *MongoDB) InsertUser(ctx context.Context, user *models.User) error {
+	result, err := m.UsersCollection().InsertOne(ctx, user)
+	if err != nil {
+		return fmt.Errorf("failed to insert user: %w", err)
+	}
+	if id, ok := result.InsertedID.(primitive.ObjectID); ok {
+		user.ID = id
+	}
+	return nil
+}
+
+// FindUserByPasskey 按 passkey 查询用户，不存在时返回 nil
+func (m *MongoDB) FindUserByPasskey(ctx context.Context, passkey string) (*models.User, error) {
+	var user models.User
+	err := m.UsersCollection().FindOne(ctx, bson.M{"passkey": passkey}).Decode(&user)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to find user: %w", err)
+	}
+	return &user, nil
+}
+
+// RevokeUserPasskey 吊销指定 passkey
+func (m *MongoDB) RevokeUserPasskey(ctx context.Context, passkey string) error {
+	update := bson.M{"$set": bson.M{"revoked": true, "revoked_at": time.Now()}}
+	result, err := m.UsersCollection().UpdateOne(ctx, bson.M{"passkey": passkey}, update)
+	if err != nil {
+		return fmt.Errorf("failed to revoke passkey: %w", err)
+	}
+	if result.MatchedCount == 0 {
+		return fmt.Errorf("passkey not found")
+	}
+	return nil
+}
+
+// Traffic 一段时间内累积的上传/下载增量
+type Traffic struct {
+	Uploaded   int64
+	Downloaded int64
+}
+
+// AddTraffic 批量累加用户和种子的流量统计（$inc + upsert，一次 BulkWrite）
+// users 的键为用户 ObjectID 的 hex，torrents 的键为 info_hash 存储标识
+func (m *MongoDB) AddTraffic(ctx context.Context, users, torrents map[string]Traffic) error {
+	now := time.Now()
+
+	if len(users) > 0 {
+		writes := make([]mongo.WriteModel, 0, len(users))
+		for userID, t := range users {
+			id, err := primitive.ObjectIDFromHex(userID)
+			if err != nil {
+				continue
+			}
+			writes = append(writes, mongo.NewUpdateOneModel().
+				SetFilter(bson.M{"_id": id}).
+				SetUpdate(bson.M{"$inc": bson.M{"uploaded": t.Uploaded, "downloaded": t.Downloaded}}))
+		}
+		if len(writes) > 0 {
+			if _, err := m.UsersCollection().BulkWrite(ctx, writes, options.BulkWrite().SetOrdered(false)); err != nil {
+				return fmt.Errorf("failed to add user traffic: %w", err)
+			}
+		}
+	}
+
+	if len(torrents) > 0 {
+		writes := make([]mongo.WriteModel, 0, len(torrents))
+		for infoHash, t := range torrents {
+			writes = append(writes, mongo.NewUpdateOneModel().
+				SetFilter(bson.M{"info_hash": infoHash}).
+				SetUpdate(bson.M{
+					"$inc": bson.M{"uploaded": t.Uploaded, "downloaded": t.Downloaded},
+					"$set": bson.M{"updated_at": now},
+				}).
+				SetUpsert(true))
+		}
+		if _, err := m.TorrentTrafficCollection().BulkWrite(ctx, writes, options.BulkWrite().SetOrdered(false)); err != nil {
+			return fmt.Errorf("failed to add torrent traffic: %w", err)
+		}
+	}
+
+	return nil
+}
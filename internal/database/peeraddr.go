@@ -0,0 +1,126 @@
+package database
+
+import (
+	"encoding/binary"
+	"net/netip"
+	"strings"
+)
+
+// Peer 地址的存储格式
+//
+// Swarm 中的 Peer（Redis ZSet 成员、peer_id 哈希字段、身份记录中的地址，以及内存存储）都以 Compact 二进制字符串保存，
+// 与 BEP-0023 / BEP-0007 响应中的格式相同：
+//   - IPv4：6 字节（4 字节 IP + 2 字节大端端口）
+//   - IPv6：18 字节（16 字节 IP + 2 字节大端端口）
+//
+// IPv4-mapped IPv6 地址（::ffff:a.b.c.d）按 IPv4 保存。构建 Compact 响应时按长度区分地址族后直接拷贝，不再解析文本地址
+//
+// 滚动升级期间旧版实例仍会写入 "IP:Port" 文本成员，读取方通过 NormalizePeerMember / ParsePeerMember 识别。
+// 长度为 18 的文本（如 "192.168.100.1:6881"）与 IPv6 成员长度相同，因此先按文本解析：
+// 能被解析为文本的 IPv6 成员首字节只能是数字或 '['，落在 IANA 保留、未分配的 3000::/4 与 5b00::/8 中，实际不会出现；
+// 长度为 6 的合法文本只有 "[::]:N"（未指定地址，不会被登记），按二进制处理
+
+const (
+	PeerMemberIPv4Len = 6  // IPv4 Peer 成员长度
+	PeerMemberIPv6Len = 18 // IPv6 Peer 成员长度
+)
+
+// PeerMember 将 Peer 地址编码为存储格式
+func PeerMember(addrPort netip.AddrPort) string {
+	var buf [PeerMemberIPv6Len]byte
+	addr := addrPort.Addr().Unmap()
+	n := copy(buf[:], addr.AsSlice())
+	binary.BigEndian.PutUint16(buf[n:], addrPort.Port())
+	return string(buf[:n+2])
+}
+
+// NormalizePeerMember 返回 Peer 的存储格式：旧版 "IP:Port" 文本成员转换为 Compact 二进制，
+// 已是存储格式时原样返回（不分配）；两者都不是时 ok 为 false
+func NormalizePeerMember(member string) (normalized string, ok bool) {
+	if maybeTextPeerMember(member) {
+		if converted, ok := textPeerMember(member); ok {
+			return converted, true
+		}
+	}
+	if len(member) == PeerMemberIPv4Len || len(member) == PeerMemberIPv6Len {
+		return member, true
+	}
+	return "", false
+}
+
+// maybeTextPeerMember 判断成员是否可能是旧版文本格式，只有可能时才尝试解析
+func maybeTextPeerMember(member string) bool {
+	switch len(member) {
+	case PeerMemberIPv4Len:
+		return false
+	case PeerMemberIPv6Len:
+		return member[0] == '[' || (member[0] >= '0' && member[0] <= '9')
+	}
+	return true
+}
+
+// ParsePeerMember 解码 Peer 地址（旧版文本成员见 NormalizePeerMember），无法识别时 ok 为 false
+func ParsePeerMember(member string) (addrPort netip.AddrPort, ok bool) {
+	member, ok = NormalizePeerMember(member)
+	if !ok {
+		return netip.AddrPort{}, false
+	}
+
+	var addr netip.Addr
+	switch len(member) {
+	case PeerMemberIPv4Len:
+		addr = netip.AddrFrom4([4]byte([]byte(member[:4])))
+	case PeerMemberIPv6Len:
+		addr = netip.AddrFrom16([16]byte([]byte(member[:16])))
+	default:
+		return netip.AddrPort{}, false
+	}
+	port := uint16(member[len(member)-2])<<8 | uint16(member[len(member)-1])
+	return netip.AddrPortFrom(addr, port), true
+}
+
+// PeerMemberString 将存储格式的 Peer 转为 "IP:Port" 文本，用于日志
+func PeerMemberString(member string) string {
+	if addrPort, ok := ParsePeerMember(member); ok {
+		return addrPort.String()
+	}
+	return "invalid"
+}
+
+// textPeerMember 将旧版 "IP:Port" 文本成员转换为存储格式，不是合法的文本地址时 ok 为 false
+func textPeerMember(text string) (member string, ok bool) {
+	addrPort, err := netip.ParseAddrPort(text)
+	if err != nil {
+		return "", false
+	}
+	return PeerMember(addrPort), true
+}
+
+// textIdentityAddrs 将旧版身份记录中以空格分隔的文本地址转换为 encodeIdentityAddrs 的格式，
+// 任一地址不是合法的文本地址时 ok 为 false（已经是新格式）
+func textIdentityAddrs(text string) (encoded string, ok bool) {
+	fields := strings.Fields(text)
+	if len(fields) == 0 {
+		return "", false
+	}
+	members := make([]string, 0, len(fields))
+	for _, field := range fields {
+		member, ok := textPeerMember(field)
+		if !ok {
+			return "", false
+		}
+		members = append(members, member)
+	}
+	return encodeIdentityAddrs(members), true
+}
+
+// encodeIdentityAddrs 身份记录的地址列表：每个地址前加 1 字节长度后拼接（二进制成员中可能出现任意字节，不能用分隔符），
+// 与 Lua 脚本中 identity addrs 的编码一致
+func encodeIdentityAddrs(members []string) string {
+	var sb strings.Builder
+	for _, member := range members {
+		sb.WriteByte(byte(len(member)))
+		sb.WriteString(member)
+	}
+	return sb.String()
+}
@@ -0,0 +1,240 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"time"
+
+	"llmpt/internal/models"
+)
+
+// PeerStore Tracker 的 Swarm 存储：Peer 登记 / 移除、选取、计数、完成次数、过期清理与限流
+//
+// 两种实现，由 PEER_STORE 选择：
+//   - Redis（默认）：多实例共享同一个 Swarm
+//   - MemoryStore：进程内分片存储，适合无需 Redis 的单机部署和测试
+//
+// infoHash 均为内部存储标识；linked 为混合种子（v1 + v2）关联的另一半，其 Peer 与主哈希合并计算。
+// Peer 地址一律为存储格式的 Compact 二进制成员（PeerMember，见 peeraddr.go），读出后可直接拼入 Compact 响应
+type PeerStore interface {
+	// Announce 一次完成 announce 对存储的全部操作：限流、登记 / 移除 Peer、完成计数、读取人数，并按
+	// GetPeersForRequest 的比例随机选取 Peer；超限返回 ErrRateLimited，key 不一致返回 ErrPeerKeyMismatch
+	Announce(ctx context.Context, op *PeerAnnounce) (*PeerAnnounceResult, error)
+
+	// AddPeer 以 (info_hash, peer_id, key) 为身份登记 Peer 的全部地址，地址变化时旧地址被原子替换；
+	// Peer 在 ttl 后过期（等待 CleanExpiredPeers 移除）；key 与已登记的不一致时返回 ErrPeerKeyMismatch
+	AddPeer(ctx context.Context, infoHash string, peers []string, peerID, key string, isSeeder bool, ttl time.Duration) error
+	// RemovePeer 移除该身份登记过的全部地址以及 peers，key 不一致时返回 ErrPeerKeyMismatch
+	RemovePeer(ctx context.Context, infoHash string, peers []string, peerID, key string) error
+
+	// GetPeersForRequest 随机选取 Peer：做种者只拿下载者；下载者拿 30% 做种者 + 70% 下载者
+	GetPeersForRequest(ctx context.Context, infoHash string, maxPeers int64, isSeeder bool, linked ...string) ([]string, error)
+	// RandomPeers 从做种者或下载者中随机取出最多 count 个 Peer
+	RandomPeers(ctx context.Context, infoHash string, seeders bool, count int, linked ...string) ([]string, error)
+	// FreshPeers 从做种者或下载者中取出过期时间最晚（通常即心跳最新）的最多 count 个 Peer
+	FreshPeers(ctx context.Context, infoHash string, seeders bool, count int, linked ...string) ([]string, error)
+	// GetPeerIDs 批量查询 Peer 的 peer_id，返回 Peer 成员 -> peer_id
+	GetPeerIDs(ctx context.Context, infoHash string, peers []string, linked ...string) (map[string]string, error)
+
+	// GetPeerCount 返回做种者和下载者数量
+	GetPeerCount(ctx context.Context, infoHash string, linked ...string) (seeders, leechers int64, err error)
+	// GetActiveTorrents 返回当前有 Peer 的种子
+	GetActiveTorrents(ctx context.Context) ([]string, error)
+	// GetSwarmPeers 返回 info_hash -> (Peer 成员 -> peer_id)
+	GetSwarmPeers(ctx context.Context, infoHashes []string) (map[string]map[string]string, error)
+
+	// IncrementCompleted 完成次数加一
+	IncrementCompleted(ctx context.Context, infoHash string) error
+	// GetCompleted 返回完成次数
+	GetCompleted(ctx context.Context, infoHash string) (int64, error)
+	// GetTorrentStats 批量返回多个种子的做种 / 下载人数与完成次数（Redis 中为一次往返），结果与 targets 一一对应
+	GetTorrentStats(ctx context.Context, targets []StatsTarget) ([]models.TorrentStats, error)
+
+	// CleanExpiredPeers 增量清理已过期（超过登记时的 ttl 没有再次 announce）的 Peer，空 Swarm 移出活跃列表；
+	// 每次最多耗时 sweep.Budget，从上次停下的位置继续。其他实例正在清理时跳过（Skipped）
+	CleanExpiredPeers(ctx context.Context, sweep *PeerSweep) (*SweepResult, error)
+
+	// CheckRateLimit 固定窗口限流，返回 false 表示超限
+	CheckRateLimit(ctx context.Context, ip string, window time.Duration, limit int) (bool, error)
+
+	// UpdatePeerSession 更新 Peer 会话并返回自上次 announce 以来的上传/下载增量（流量统计）
+	UpdatePeerSession(ctx context.Context, infoHash, identity string, uploaded, downloaded int64, started, stopped bool, ttl time.Duration) (deltaUp, deltaDown int64, err error)
+
+	// Ping 测量一次存储往返延迟（用于自适应 interval）
+	Ping(ctx context.Context) (time.Duration, error)
+}
+
+// ErrRateLimited 请求方超过 announce 频率限制
+var ErrRateLimited = errors.New("rate limit exceeded")
+
+// PeerAnnounce 一次 announce 对 Peer 存储的操作
+type PeerAnnounce struct {
+	InfoHash  string
+	Linked    []string // 混合种子关联的另一半，计入人数和选取
+	Peers     []string // 请求方的全部地址（PeerMember）
+	PeerID    string
+	Key       string
+	Seeder    bool
+	Stopped   bool          // stopped 事件：移除 Peer，不选取
+	Completed bool          // completed 事件：完成次数加一
+	TTL       time.Duration // Peer 的存活时间，超过后没有再次 announce 即视为过期
+
+	RateLimitIP     string // 限流对象，为空表示不限流（调用方已单独检查）
+	RateLimitWindow time.Duration
+	RateLimitBurst  int
+
+	NumWant int // 随机选取的 Peer 数（结果可能包含请求方自己），0 表示不选取
+}
+
+// StatsTarget GetTorrentStats 的一个查询目标，Linked 的人数和完成次数合计在内
+type StatsTarget struct {
+	InfoHash string
+	Linked   []string // 混合种子关联的另一半
+}
+
+// PeerSweep 一次过期 Peer 清理的参数
+type PeerSweep struct {
+	Budget time.Duration // 本次最多耗时，用完后记下扫描位置，下次继续；0 表示扫完一整轮
+	Lease  time.Duration // 清理租约时长：持有租约的实例独占清理，每次清理时续期；0 表示不加锁
+}
+
+// SweepResult 一次清理的结果
+type SweepResult struct {
+	Skipped        bool  // 租约被其他实例持有，本次未清理
+	Scanned        int   // 检查的种子数
+	PeersReaped    int64 // 移除的过期 Peer 数
+	TorrentsReaped int64 // 变空而移出活跃列表的种子数
+	Wrapped        bool  // 本次扫完了一整轮，下次从头开始
+}
+
+// PeerAnnounceResult Announce 的结果，人数为登记 / 移除之后的数量
+type PeerAnnounceResult struct {
+	Seeders  int64
+	Leechers int64
+	Peers    []string
+}
+
+// announceSteps 依次调用各个方法完成 Announce，供单次往返没有意义的进程内存储使用
+func announceSteps(ctx context.Context, s PeerStore, op *PeerAnnounce) (*PeerAnnounceResult, error) {
+	if op.RateLimitIP != "" {
+		allowed, err := s.CheckRateLimit(ctx, op.RateLimitIP, op.RateLimitWindow, op.RateLimitBurst)
+		if err != nil {
+			return nil, err
+		}
+		if !allowed {
+			return nil, ErrRateLimited
+		}
+	}
+
+	result := &PeerAnnounceResult{}
+	if op.Stopped {
+		if err := s.RemovePeer(ctx, op.InfoHash, op.Peers, op.PeerID, op.Key); err != nil {
+			return nil, err
+		}
+	} else {
+		if err := s.AddPeer(ctx, op.InfoHash, op.Peers, op.PeerID, op.Key, op.Seeder, op.TTL); err != nil {
+			return nil, err
+		}
+		if op.Completed {
+			if err := s.IncrementCompleted(ctx, op.InfoHash); err != nil {
+				return nil, err
+			}
+		}
+		if op.NumWant > 0 {
+			peers, err := s.GetPeersForRequest(ctx, op.InfoHash, int64(op.NumWant), op.Seeder, op.Linked...)
+			if err != nil {
+				return nil, err
+			}
+			result.Peers = peers
+		}
+	}
+
+	var err error
+	result.Seeders, result.Leechers, err = s.GetPeerCount(ctx, op.InfoHash, op.Linked...)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+var (
+	_ PeerStore = (*Redis)(nil)
+	_ PeerStore = (*MemoryStore)(nil)
+)
+
+// MixPeers 按比例混合做种者和下载者，fetch 决定每一侧的取法（随机、最新等）
+// 做种者只拿下载者；下载者拿 30% 做种者 + 70% 下载者，任一侧不足时由另一侧补足。
+// GetPeersForRequest 与 Tracker 中按同样比例选取的策略共用这一实现
+func MixPeers(maxPeers int, isSeeder bool, fetch func(seeders bool, count int) ([]string, error)) ([]string, error) {
+	if isSeeder {
+		// 如果是做种者，全部返回 Leecher
+		return fetch(false, maxPeers)
+	}
+
+	// 如果是下载者，按 3:7 比例请求
+	seederQuota := int(float64(maxPeers) * 0.3)
+	if seederQuota < 1 && maxPeers > 0 {
+		seederQuota = 1
+	}
+	leecherQuota := maxPeers - seederQuota
+
+	// 获取 Seeders
+	seeders, err := fetch(true, seederQuota)
+	if err != nil {
+		return nil, err
+	}
+
+	// 获取 Leechers
+	leechers, err := fetch(false, leecherQuota)
+	if err != nil {
+		return nil, err
+	}
+
+	// 如果 Seeder 不够 30%，用 Leecher 补足
+	if len(seeders) < seederQuota {
+		shortfall := seederQuota - len(seeders)
+		leechers, err = fetch(false, leecherQuota+shortfall)
+		if err != nil {
+			return nil, err
+		}
+	} else if len(leechers) < leecherQuota {
+		// 如果 Leecher 不够 70%，用 Seeder 补足
+		shortfall := leecherQuota - len(leechers)
+		seeders, err = fetch(true, seederQuota+shortfall)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	peers := make([]string, 0, len(seeders)+len(leechers))
+	peers = append(peers, seeders...)
+	peers = append(peers, leechers...)
+
+	// 确保不超标（理论上不同角色不会重复，这里为了性能不再去重）
+	if len(peers) > maxPeers {
+		peers = peers[:maxPeers]
+	}
+	return peers, nil
+}
+
+// mergePeerLists 合并从多个哈希（混合种子的 v1 与 v2）各自随机取出的 Peer：去重、打乱再截断到 count 个，
+// 避免偏向其中一边；同一个 Peer（如同时汇报 v1 和 v2 哈希的混合客户端）只会出现一次
+func mergePeerLists(lists [][]string, count int) []string {
+	seen := make(map[string]bool)
+	var peers []string
+	for _, list := range lists {
+		for _, p := range list {
+			if !seen[p] {
+				seen[p] = true
+				peers = append(peers, p)
+			}
+		}
+	}
+
+	rand.Shuffle(len(peers), func(i, j int) { peers[i], peers[j] = peers[j], peers[i] })
+	if len(peers) > count {
+		peers = peers[:count]
+	}
+	return peers
+}
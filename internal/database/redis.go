@@ -2,64 +2,112 @@ package database
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/redis/go-redis/v9"
+
+	"llmpt/internal/config"
+	"llmpt/internal/models"
 )
 
 // Redis Redis 客户端包装
+// Client 为单机、哨兵或集群客户端之一，按 REDIS_MODE 创建
 type Redis struct {
-	Client *redis.Client
+	Client  redis.UniversalClient
+	cluster bool   // Cluster 模式：跨 slot 的键不能放进同一个脚本
+	owner   string // 实例 ID（主机名:PID:随机数），用作清理租约的持有者
 }
 
-// RedisPoolOptions Redis 连接池配置
-type RedisPoolOptions struct {
-	PoolSize     int
-	MinIdleConns int
+// RedisOptions Redis 连接配置
+type RedisOptions struct {
+	Mode             string   // config.RedisModeStandalone / RedisModeSentinel / RedisModeCluster
+	Addrs            []string // 单机：第一个地址；哨兵：哨兵地址；集群：任意几个种子节点
+	MasterName       string   // 哨兵模式下的主节点名称
+	Password         string
+	SentinelPassword string
+	DB               int // 集群模式只能为 0
+	PoolSize         int // 每个节点的连接池大小，默认 50
+	MinIdleConns     int // 每个节点的最小空闲连接数，默认 10
 }
 
 // NewRedis 创建新的 Redis 连接
-// poolOpts 为 nil 时使用默认连接池配置（50/10）
-func NewRedis(addr, password string, db int, poolOpts *RedisPoolOptions) (*Redis, error) {
+func NewRedis(opts *RedisOptions) (*Redis, error) {
 	poolSize := 50
 	minIdleConns := 10
-	if poolOpts != nil {
-		if poolOpts.PoolSize > 0 {
-			poolSize = poolOpts.PoolSize
-		}
-		if poolOpts.MinIdleConns > 0 {
-			minIdleConns = poolOpts.MinIdleConns
-		}
+	if opts.PoolSize > 0 {
+		poolSize = opts.PoolSize
+	}
+	if opts.MinIdleConns > 0 {
+		minIdleConns = opts.MinIdleConns
 	}
 
-	client := redis.NewClient(&redis.Options{
-		Addr:         addr,
-		Password:     password,
-		DB:           db,
-		PoolSize:     poolSize,
-		MinIdleConns: minIdleConns,
-		MaxRetries:   3,
-		DialTimeout:  5 * time.Second,
-		ReadTimeout:  3 * time.Second,
-		WriteTimeout: 3 * time.Second,
-	})
+	universal := &redis.UniversalOptions{
+		Addrs:            opts.Addrs,
+		MasterName:       opts.MasterName,
+		Password:         opts.Password,
+		SentinelPassword: opts.SentinelPassword,
+		DB:               opts.DB,
+		PoolSize:         poolSize,
+		MinIdleConns:     minIdleConns,
+		MaxRetries:       3,
+		DialTimeout:      5 * time.Second,
+		ReadTimeout:      3 * time.Second,
+		WriteTimeout:     3 * time.Second,
+	}
+
+	// 显式按模式创建：NewUniversalClient 只凭地址数量判断集群，只配置一个种子节点时会误建单机客户端
+	var client redis.UniversalClient
+	switch opts.Mode {
+	case config.RedisModeSentinel:
+		client = redis.NewFailoverClient(universal.Failover())
+	case config.RedisModeCluster:
+		client = redis.NewClusterClient(universal.Cluster())
+	default:
+		client = redis.NewClient(universal.Simple())
+	}
 
 	// 测试连接
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
 	if err := client.Ping(ctx).Err(); err != nil {
+		client.Close()
 		return nil, fmt.Errorf("failed to connect to Redis: %w", err)
 	}
 
-	fmt.Printf("✓ Successfully connected to Redis (addr: %s)\n", addr)
+	// 预加载 Lua 脚本（SCRIPT LOAD，集群模式下加载到所有主节点），热路径上只发送 EVALSHA
+	for _, script := range []*redis.Script{announceScript, peerSessionScript, reapScript} {
+		if err := script.Load(ctx, client).Err(); err != nil {
+			client.Close()
+			return nil, fmt.Errorf("failed to load Redis script: %w", err)
+		}
+	}
+
+	fmt.Printf("✓ Successfully connected to Redis (mode: %s, addrs: %s)\n", opts.Mode, strings.Join(opts.Addrs, ","))
 
 	return &Redis{
-		Client: client,
+		Client:  client,
+		cluster: opts.Mode == config.RedisModeCluster,
+		owner:   instanceID(),
 	}, nil
 }
 
+// instanceID 生成区分 Tracker 实例的 ID
+func instanceID() string {
+	host, _ := os.Hostname()
+	suffix := make([]byte, 4)
+	rand.Read(suffix)
+	return fmt.Sprintf("%s:%d:%x", host, os.Getpid(), suffix)
+}
+
 // Close 关闭 Redis 连接
 func (r *Redis) Close() error {
 	if r.Client != nil {
@@ -70,165 +118,683 @@ func (r *Redis) Close() error {
 
 // Tracker Peer 相关方法
 
-// AddPeer 添加 Peer 到指定 info_hash 的有序集合中，同时维护活跃种子列表
-func (r *Redis) AddPeer(ctx context.Context, infoHash, peer string, isSeeder bool, ttl time.Duration) error {
-	seederKey := fmt.Sprintf("tracker:seeders:%s", infoHash)
-	leecherKey := fmt.Sprintf("tracker:leechers:%s", infoHash)
-	activeKey := "tracker:active_torrents"
+// ErrPeerKeyMismatch announce 携带的 key 与该 peer_id 已登记的 key 不一致（疑似冒用他人身份）
+var ErrPeerKeyMismatch = errors.New("peer key mismatch")
 
-	now := float64(time.Now().Unix())
-	pipe := r.Client.Pipeline()
+// Ping 测量一次 Redis 往返延迟
+func (r *Redis) Ping(ctx context.Context) (time.Duration, error) {
+	start := time.Now()
+	err := r.Client.Ping(ctx).Err()
+	return time.Since(start), err
+}
+
+// announceScript 单次往返完成一次 announce：限流、登记 / 移除 Peer、完成计数、读取人数并随机采样 Peer
+// AddPeer / RemovePeer 也通过它登记或移除 Peer（不限流、不计数、不采样），身份逻辑只有这一份
+// 启动时 SCRIPT LOAD，之后以 EVALSHA 调用（Redis 重启丢失脚本缓存时 go-redis 自动回退到 EVAL）
+// KEYS: seeders, leechers, peerids, identity, active, stats, [ratelimit]（除限流键外同属一个 slot）
+// ARGV: mode(add/remove), peer_id, key, deadline(Unix 秒), ttl(秒), seeder(0/1), info_hash,
+// completed(0/1), rate limit(0 表示不限流，此时不传限流键), rate window(秒), sample(每个集合采样数，0 表示不采样), addr...
+// addr 为存储格式（peeraddr.go）的 Compact 二进制成员，ZSet 分数为 Peer 的过期时间（announce 时间 + ttl）；
+// 集合键的 TTL 只延长不缩短，保持不短于其中最晚过期的 Peer，身份记录与本次 Peer 同时过期
+// 身份记录（KEYS[4]）以 (info_hash, peer_id, key) 为 Peer 身份，保存登记时的 key 和地址列表
+// （每个地址前加 1 字节长度后拼接，见 encodeIdentityAddrs）：
+//   - 已登记 key 与本次不一致时拒绝（包括登记时未带 key、本次带 key 的情况），防止他人用相同 peer_id 顶替
+//   - add：IP / 端口变化后，旧地址从 ZSet 中移除，新地址加入，不会留下幽灵 Peer
+//   - remove：移除身份记录中的全部地址以及本次地址
+//
+// 返回 {状态, 做种者数, 下载者数, 做种者样本, 下载者样本}，状态 -2 表示超限、-1 表示 key 不一致；做种者只采样下载者
+var announceScript = redis.NewScript(`
+local limit = tonumber(ARGV[9])
+if limit > 0 then
+  local n = redis.call('INCR', KEYS[7])
+  if n == 1 then redis.call('EXPIRE', KEYS[7], ARGV[10]) end
+  if n > limit then return {-2} end
+end
+
+local prevKey = redis.call('HGET', KEYS[4], 'key')
+if prevKey and prevKey ~= ARGV[3] then
+  return {-1}
+end
+
+local current = {}
+for i = 12, #ARGV do current[ARGV[i]] = true end
+
+local prevAddrs = redis.call('HGET', KEYS[4], 'addrs')
+if prevAddrs then
+  local pos = 1
+  while pos <= #prevAddrs do
+    local len = string.byte(prevAddrs, pos)
+    local addr = string.sub(prevAddrs, pos + 1, pos + len)
+    pos = pos + 1 + len
+    if ARGV[1] == 'remove' or not current[addr] then
+      redis.call('ZREM', KEYS[1], addr)
+      redis.call('ZREM', KEYS[2], addr)
+      redis.call('HDEL', KEYS[3], addr)
+    end
+  end
+end
+
+if ARGV[1] == 'remove' then
+  for i = 12, #ARGV do
+    redis.call('ZREM', KEYS[1], ARGV[i])
+    redis.call('ZREM', KEYS[2], ARGV[i])
+    redis.call('HDEL', KEYS[3], ARGV[i])
+  end
+  redis.call('DEL', KEYS[4])
+else
+  local ttl = tonumber(ARGV[5])
+  local addKey, remKey = KEYS[2], KEYS[1]
+  if ARGV[6] == '1' then addKey, remKey = KEYS[1], KEYS[2] end
+  for i = 12, #ARGV do
+    redis.call('ZADD', addKey, ARGV[4], ARGV[i])
+    redis.call('ZREM', remKey, ARGV[i])
+    redis.call('HSET', KEYS[3], ARGV[i], ARGV[2])
+  end
+  if redis.call('TTL', addKey) < ttl then redis.call('EXPIRE', addKey, ttl) end
+  if redis.call('TTL', KEYS[3]) < ttl then redis.call('EXPIRE', KEYS[3], ttl) end
+
+  local addrs = {}
+  for i = 12, #ARGV do addrs[#addrs + 1] = string.char(#ARGV[i]) .. ARGV[i] end
+  redis.call('HSET', KEYS[4], 'key', ARGV[3], 'addrs', table.concat(addrs))
+  redis.call('EXPIRE', KEYS[4], ttl)
+  redis.call('SADD', KEYS[5], ARGV[7])
+
+  if ARGV[8] == '1' then
+    redis.call('HINCRBY', KEYS[6], 'completed', 1)
+  end
+end
+
+local sample = tonumber(ARGV[11])
+local seederSample, leecherSample = {}, {}
+if sample > 0 then
+  if ARGV[6] ~= '1' then
+    seederSample = redis.call('ZRANDMEMBER', KEYS[1], sample)
+  end
+  leecherSample = redis.call('ZRANDMEMBER', KEYS[2], sample)
+end
+return {1, redis.call('ZCARD', KEYS[1]), redis.call('ZCARD', KEYS[2]), seederSample, leecherSample}
+`)
+
+// AddPeer 登记 Peer 的全部地址（PeerMember，双栈 Peer 可有两个），同时维护活跃种子列表
+// Peer 身份为 (info_hash, peer_id, key)：同一身份地址变化时旧地址被原子替换；
+// key 与已登记的不一致时返回 ErrPeerKeyMismatch
+// peerID 记录在 tracker:{sXX}:peerids:{info_hash} 哈希中（字段为 Peer 成员），供非 Compact 响应返回
+func (r *Redis) AddPeer(ctx context.Context, infoHash string, peers []string, peerID, key string, isSeeder bool, ttl time.Duration) error {
+	_, err := r.runAnnounceScript(ctx, &PeerAnnounce{
+		InfoHash: infoHash,
+		Peers:    peers,
+		PeerID:   peerID,
+		Key:      key,
+		Seeder:   isSeeder,
+		TTL:      ttl,
+	}, "", 0)
+	return err
+}
+
+// RemovePeer 移除 Peer 的全部地址（同时从两边移除以防万一），key 不一致时返回 ErrPeerKeyMismatch
+func (r *Redis) RemovePeer(ctx context.Context, infoHash string, peers []string, peerID, key string) error {
+	_, err := r.runAnnounceScript(ctx, &PeerAnnounce{
+		InfoHash: infoHash,
+		Peers:    peers,
+		PeerID:   peerID,
+		Key:      key,
+		Stopped:  true,
+	}, "", 0)
+	return err
+}
 
-	if isSeeder {
-		// 添加到做种者集合
-		pipe.ZAdd(ctx, seederKey, redis.Z{Score: now, Member: peer})
-		// 从下载者集合中移除（防止状态反转时两头占坑）
-		pipe.ZRem(ctx, leecherKey, peer)
-		pipe.Expire(ctx, seederKey, ttl)
-	} else {
-		// 添加到下载者集合
-		pipe.ZAdd(ctx, leecherKey, redis.Z{Score: now, Member: peer})
-		// 从做种者集合中移除
-		pipe.ZRem(ctx, seederKey, peer)
-		pipe.Expire(ctx, leecherKey, ttl)
+// Announce 完成整个 announce，采样结果按 GetPeersForRequest 的比例混合。Redis 往返次数：
+//   - 普通种子：1 次（EVALSHA，原子执行）
+//   - 混合种子：2 次，另一半哈希可能位于其他 slot，不放进脚本，其人数与样本随后用一次 Pipeline 读取
+//   - Cluster 模式且按 IP 限流：再加 1 次，限流键与种子键不在同一 slot，限流在脚本之前单独检查
+func (r *Redis) Announce(ctx context.Context, op *PeerAnnounce) (*PeerAnnounceResult, error) {
+	rateLimitIP := ""
+	if op.RateLimitIP != "" {
+		if r.cluster {
+			allowed, err := r.CheckRateLimit(ctx, op.RateLimitIP, op.RateLimitWindow, op.RateLimitBurst)
+			if err != nil {
+				return nil, err
+			}
+			if !allowed {
+				return nil, ErrRateLimited
+			}
+		} else {
+			rateLimitIP = op.RateLimitIP
+		}
 	}
 
-	// 记录活跃的种子，方便后续清理
-	pipe.SAdd(ctx, activeKey, infoHash)
+	sample := op.NumWant
+	if op.Stopped {
+		sample = 0
+	}
+	reply, err := r.runAnnounceScript(ctx, op, rateLimitIP, sample)
+	if err != nil {
+		return nil, err
+	}
 
-	_, err := pipe.Exec(ctx)
-	return err
+	seeders, _ := reply[1].(int64)
+	leechers, _ := reply[2].(int64)
+	result := &PeerAnnounceResult{Seeders: seeders, Leechers: leechers}
+	seederLists := [][]string{stringSlice(reply[3])}
+	leecherLists := [][]string{stringSlice(reply[4])}
+
+	if len(op.Linked) > 0 {
+		// 混合种子：一次 Pipeline 读取另一半哈希的人数与样本，与主哈希合并
+		pipe := r.Client.Pipeline()
+		counts := make([][2]*redis.IntCmd, len(op.Linked))
+		samples := make([][2]*redis.Cmd, len(op.Linked))
+		for i, hash := range op.Linked {
+			counts[i] = [2]*redis.IntCmd{pipe.ZCard(ctx, seedersKey(hash)), pipe.ZCard(ctx, leechersKey(hash))}
+			if sample > 0 {
+				if !op.Seeder {
+					samples[i][0] = pipe.Do(ctx, "ZRANDMEMBER", seedersKey(hash), sample)
+				}
+				samples[i][1] = pipe.Do(ctx, "ZRANDMEMBER", leechersKey(hash), sample)
+			}
+		}
+		if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+			return nil, err
+		}
+
+		for i := range op.Linked {
+			result.Seeders += counts[i][0].Val()
+			result.Leechers += counts[i][1].Val()
+			if samples[i][0] != nil {
+				list, _ := samples[i][0].StringSlice()
+				seederLists = append(seederLists, list)
+			}
+			if samples[i][1] != nil {
+				list, _ := samples[i][1].StringSlice()
+				leecherLists = append(leecherLists, list)
+			}
+		}
+	}
+
+	if sample > 0 {
+		// 每个集合各采样了 NumWant 个，足够覆盖任一侧不足时的补足，在本地按比例截取
+		seederPool := mergePeerLists(seederLists, sample)
+		leecherPool := mergePeerLists(leecherLists, sample)
+		result.Peers, _ = MixPeers(sample, op.Seeder, func(seeders bool, count int) ([]string, error) {
+			pool := leecherPool
+			if seeders {
+				pool = seederPool
+			}
+			return pool[:min(count, len(pool))], nil
+		})
+	}
+	return result, nil
+}
+
+// runAnnounceScript 执行 announceScript，rateLimitIP 为空时不限流，sample 为每个集合的采样数
+// 超限返回 ErrRateLimited，key 不一致返回 ErrPeerKeyMismatch
+func (r *Redis) runAnnounceScript(ctx context.Context, op *PeerAnnounce, rateLimitIP string, sample int) ([]interface{}, error) {
+	keys := []string{
+		seedersKey(op.InfoHash),
+		leechersKey(op.InfoHash),
+		peerIDsKey(op.InfoHash),
+		identityKey(op.InfoHash, op.PeerID),
+		activeKey(op.InfoHash),
+		statsKey(op.InfoHash),
+	}
+	limit := 0
+	if rateLimitIP != "" {
+		limit = op.RateLimitBurst
+		keys = append(keys, rateLimitKey(rateLimitIP))
+	}
+
+	mode := "add"
+	if op.Stopped {
+		mode = "remove"
+	}
+
+	args := make([]interface{}, 0, 11+len(op.Peers))
+	args = append(args, mode, op.PeerID, op.Key, time.Now().Add(op.TTL).Unix(),
+		int64(op.TTL.Seconds()), boolArg(op.Seeder), op.InfoHash,
+		boolArg(op.Completed), limit, int64(op.RateLimitWindow.Seconds()), sample)
+	for _, peer := range op.Peers {
+		args = append(args, peer)
+	}
+
+	reply, err := announceScript.Run(ctx, r.Client, keys, args...).Slice()
+	if err != nil {
+		return nil, err
+	}
+	switch status, _ := reply[0].(int64); status {
+	case -2:
+		return nil, ErrRateLimited
+	case -1:
+		return nil, ErrPeerKeyMismatch
+	}
+	return reply, nil
+}
+
+// stringSlice 将脚本返回的数组转为 []string
+func stringSlice(v interface{}) []string {
+	items, _ := v.([]interface{})
+	list := make([]string, 0, len(items))
+	for _, item := range items {
+		if s, ok := item.(string); ok {
+			list = append(list, s)
+		}
+	}
+	return list
 }
 
 // GetPeersForRequest 智能获取 Peer 列表，按比例混合做种者和下载者
 // 策略：做种者（Seeder）只拿下载者（Leecher）；下载者则混合拿 30% Seeders + 70% Leechers
-func (r *Redis) GetPeersForRequest(ctx context.Context, infoHash string, maxPeers int64, isSeeder bool) ([]string, error) {
-	seederKey := fmt.Sprintf("tracker:seeders:%s", infoHash)
-	leecherKey := fmt.Sprintf("tracker:leechers:%s", infoHash)
+// linked 为混合种子（v1 + v2）关联的另一半 info_hash，其 Peer 会合并到同一个候选池中
+func (r *Redis) GetPeersForRequest(ctx context.Context, infoHash string, maxPeers int64, isSeeder bool, linked ...string) ([]string, error) {
+	return MixPeers(int(maxPeers), isSeeder, func(seeders bool, count int) ([]string, error) {
+		return r.RandomPeers(ctx, infoHash, seeders, count, linked...)
+	})
+}
 
-	var peers []string
+// RandomPeers 从做种者（seeders=true）或下载者集合中随机取出最多 count 个 Peer
+// linked 为混合种子关联的另一半 info_hash
+func (r *Redis) RandomPeers(ctx context.Context, infoHash string, seeders bool, count int, linked ...string) ([]string, error) {
+	return r.randMembers(ctx, torrentKeys(func(ih string) string { return roleKey(seeders, ih) }, infoHash, linked), count)
+}
 
-	if isSeeder {
-		// 如果是做种者，全部返回 Leecher
-		leechers, err := r.Client.Do(ctx, "ZRANDMEMBER", leecherKey, int(maxPeers)).StringSlice()
-		if err != nil && err != redis.Nil {
-			return nil, err
+// FreshPeers 从做种者或下载者集合中取出过期时间最晚（ZSet 分数最高，通常即心跳最新）的最多 count 个 Peer
+// linked 为混合种子关联的另一半 info_hash，多个 ZSet 的结果按分数合并
+func (r *Redis) FreshPeers(ctx context.Context, infoHash string, seeders bool, count int, linked ...string) ([]string, error) {
+	if count <= 0 {
+		return nil, nil
+	}
+
+	keys := torrentKeys(func(ih string) string { return roleKey(seeders, ih) }, infoHash, linked)
+	pipe := r.Client.Pipeline()
+	cmds := make([]*redis.ZSliceCmd, len(keys))
+	for i, key := range keys {
+		cmds[i] = pipe.ZRevRangeWithScores(ctx, key, 0, int64(count-1))
+	}
+	if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+		return nil, err
+	}
+
+	var merged []redis.Z
+	for _, cmd := range cmds {
+		merged = append(merged, cmd.Val()...)
+	}
+	sort.SliceStable(merged, func(i, j int) bool { return merged[i].Score > merged[j].Score })
+
+	seen := make(map[string]bool, len(merged))
+	peers := make([]string, 0, count)
+	for _, z := range merged {
+		member, _ := z.Member.(string)
+		if seen[member] {
+			continue
 		}
-		peers = leechers
-	} else {
-		// 如果是下载者，按 3:7 比例请求
-		seederQuota := int(float64(maxPeers) * 0.3)
-		if seederQuota < 1 && maxPeers > 0 {
-			seederQuota = 1
+		seen[member] = true
+		peers = append(peers, member)
+		if len(peers) == count {
+			break
 		}
-		leecherQuota := int(maxPeers) - seederQuota
+	}
+	return peers, nil
+}
 
-		// 获取 Seeders
-		seeders, err := r.Client.Do(ctx, "ZRANDMEMBER", seederKey, seederQuota).StringSlice()
-		if err != nil && err != redis.Nil {
-			return nil, err
-		}
+// randMembers 从一个或多个 ZSet 中随机取出最多 count 个成员，多个 ZSet 时各取 count 个后由 mergePeerLists 合并
+func (r *Redis) randMembers(ctx context.Context, keys []string, count int) ([]string, error) {
+	if count <= 0 {
+		return nil, nil
+	}
 
-		// 获取 Leechers
-		leechers, err := r.Client.Do(ctx, "ZRANDMEMBER", leecherKey, leecherQuota).StringSlice()
+	if len(keys) == 1 {
+		members, err := r.Client.Do(ctx, "ZRANDMEMBER", keys[0], count).StringSlice()
 		if err != nil && err != redis.Nil {
 			return nil, err
 		}
+		return members, nil
+	}
 
-		// 如果 Seeder 不够 30%，用 Leecher 补足
-		if len(seeders) < seederQuota {
-			shortfall := seederQuota - len(seeders)
-			extraLeechers, _ := r.Client.Do(ctx, "ZRANDMEMBER", leecherKey, leecherQuota+shortfall).StringSlice()
-			leechers = extraLeechers
-		} else if len(leechers) < leecherQuota {
-			// 如果 Leecher 不够 70%，用 Seeder 补足
-			shortfall := leecherQuota - len(leechers)
-			extraSeeders, _ := r.Client.Do(ctx, "ZRANDMEMBER", seederKey, seederQuota+shortfall).StringSlice()
-			seeders = extraSeeders
-		}
-
-		peers = append(peers, seeders...)
-		peers = append(peers, leechers...)
+	pipe := r.Client.Pipeline()
+	cmds := make([]*redis.Cmd, len(keys))
+	for i, key := range keys {
+		cmds[i] = pipe.Do(ctx, "ZRANDMEMBER", key, count)
+	}
+	if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+		return nil, err
+	}
 
-		// 确保不超标并去重（理论上 ZSet 不同角色不会重复，但如果补足逻辑导致重合，可以用 map 去重，这里为了性能直接返回）
-		if len(peers) > int(maxPeers) {
-			peers = peers[:maxPeers]
+	lists := make([][]string, len(cmds))
+	for i, cmd := range cmds {
+		list, err := cmd.StringSlice()
+		if err != nil && err != redis.Nil {
+			return nil, err
 		}
+		lists[i] = list
 	}
-
-	return peers, nil
+	return mergePeerLists(lists, count), nil
 }
 
-// RemovePeer 从种子中移除指定的 Peer（同时从两边移除以防万一）
-func (r *Redis) RemovePeer(ctx context.Context, infoHash, peer string) error {
-	seederKey := fmt.Sprintf("tracker:seeders:%s", infoHash)
-	leecherKey := fmt.Sprintf("tracker:leechers:%s", infoHash)
+// GetPeerIDs 批量查询 Peer 的 peer_id，返回 Peer 成员 -> peer_id
+// linked 为混合种子关联的另一半 info_hash，Peer 可能登记在任意一边
+func (r *Redis) GetPeerIDs(ctx context.Context, infoHash string, peers []string, linked ...string) (map[string]string, error) {
+	result := make(map[string]string, len(peers))
+	if len(peers) == 0 {
+		return result, nil
+	}
+
+	keys := torrentKeys(peerIDsKey, infoHash, linked)
 
 	pipe := r.Client.Pipeline()
-	pipe.ZRem(ctx, seederKey, peer)
-	pipe.ZRem(ctx, leecherKey, peer)
-	_, err := pipe.Exec(ctx)
-	return err
+	cmds := make([]*redis.SliceCmd, len(keys))
+	for i, key := range keys {
+		cmds[i] = pipe.HMGet(ctx, key, peers...)
+	}
+	if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+		return nil, err
+	}
+
+	for _, cmd := range cmds {
+		for i, v := range cmd.Val() {
+			if id, ok := v.(string); ok {
+				if _, found := result[peers[i]]; !found {
+					result[peers[i]] = id
+				}
+			}
+		}
+	}
+	return result, nil
 }
 
 // GetPeerCount 获取精准的做种者和下载者数量
-func (r *Redis) GetPeerCount(ctx context.Context, infoHash string) (seeders, leechers int64, err error) {
-	seederKey := fmt.Sprintf("tracker:seeders:%s", infoHash)
-	leecherKey := fmt.Sprintf("tracker:leechers:%s", infoHash)
+// linked 为混合种子关联的另一半 info_hash，返回两者合计的数量
+func (r *Redis) GetPeerCount(ctx context.Context, infoHash string, linked ...string) (seeders, leechers int64, err error) {
+	seederKeys := torrentKeys(seedersKey, infoHash, linked)
+	leecherKeys := torrentKeys(leechersKey, infoHash, linked)
 
 	// 使用 Pipeline 提高效率
 	pipe := r.Client.Pipeline()
-	sCmd := pipe.ZCard(ctx, seederKey)
-	lCmd := pipe.ZCard(ctx, leecherKey)
+	sCmds := make([]*redis.IntCmd, len(seederKeys))
+	lCmds := make([]*redis.IntCmd, len(leecherKeys))
+	for i := range seederKeys {
+		sCmds[i] = pipe.ZCard(ctx, seederKeys[i])
+		lCmds[i] = pipe.ZCard(ctx, leecherKeys[i])
+	}
 
 	_, err = pipe.Exec(ctx)
 	if err != nil {
 		return 0, 0, err
 	}
 
-	return sCmd.Val(), lCmd.Val(), nil
+	for i := range sCmds {
+		seeders += sCmds[i].Val()
+		leechers += lCmds[i].Val()
+	}
+	return seeders, leechers, nil
 }
 
-// CleanExpiredPeers 清理全局所有的超时节点
-func (r *Redis) CleanExpiredPeers(ctx context.Context, timeout time.Duration) error {
-	activeKey := "tracker:active_torrents"
+// GetSwarmPeers 批量获取多个种子中全部 Peer 的地址与 peer_id
+// 返回 info_hash -> (Peer 成员 -> peer_id)，双栈 Peer 的两个地址对应同一个 peer_id
+func (r *Redis) GetSwarmPeers(ctx context.Context, infoHashes []string) (map[string]map[string]string, error) {
+	pipe := r.Client.Pipeline()
+	cmds := make([]*redis.MapStringStringCmd, len(infoHashes))
+	for i, infoHash := range infoHashes {
+		cmds[i] = pipe.HGetAll(ctx, peerIDsKey(infoHash))
+	}
+	if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+		return nil, err
+	}
 
-	// 获取所有活跃的 info_hash
-	infoHashes, err := r.Client.SMembers(ctx, activeKey).Result()
-	if err != nil {
-		return err
+	result := make(map[string]map[string]string, len(infoHashes))
+	for i, cmd := range cmds {
+		result[infoHashes[i]] = cmd.Val()
 	}
+	return result, nil
+}
 
-	// 死亡判定线：早于这个时间戳更新的心跳统统算死节点
-	deathLine := float64(time.Now().Unix() - int64(timeout.Seconds()))
-	deathLineStr := fmt.Sprintf("%f", deathLine)
+// 过期 Peer 清理的协调键（与种子无关，不带哈希标签）
+const (
+	cleanupLeaseKey  = "tracker:cleanup:lease"  // 清理租约，值为持有者的实例 ID
+	cleanupCursorKey = "tracker:cleanup:cursor" // 扫描进度（分片与 SSCAN 游标），租约换手后由新持有者接着扫
+	cleanupScanCount = 100                      // 每次 SSCAN 的建议返回数量
+)
 
-	for _, infoHash := range infoHashes {
-		seederKey := fmt.Sprintf("tracker:seeders:%s", infoHash)
-		leecherKey := fmt.Sprintf("tracker:leechers:%s", infoHash)
+// cleanupLeaseScript 获取或续期清理租约：租约空闲或已由自己持有时设置并返回 1，被其他实例持有时返回 0
+// KEYS[1] = lease
+// ARGV: owner, ttl(毫秒)
+var cleanupLeaseScript = redis.NewScript(`
+local holder = redis.call('GET', KEYS[1])
+if holder and holder ~= ARGV[1] then
+  return 0
+end
+redis.call('SET', KEYS[1], ARGV[1], 'PX', ARGV[2])
+return 1
+`)
+
+// reapScript 移除一个种子中已过期（分数早于当前时间）的 Peer 及其 peer_id，Swarm 变空时移出活跃列表并删除 peer_id 哈希
+// 在同一个脚本中判断与移除，不会把刚刚 announce 进来的种子误移出活跃列表
+// KEYS: seeders, leechers, peerids, active（同属一个 slot）
+// ARGV: now(Unix 秒), info_hash
+// 返回 {移除的 Peer 数, 是否移出活跃列表(0/1)}
+var reapScript = redis.NewScript(`
+local reaped = 0
+for i = 1, 2 do
+  local dead = redis.call('ZRANGEBYSCORE', KEYS[i], '-inf', '(' .. ARGV[1])
+  if #dead > 0 then
+    redis.call('ZREMRANGEBYSCORE', KEYS[i], '-inf', '(' .. ARGV[1])
+    for j = 1, #dead, 1000 do
+      redis.call('HDEL', KEYS[3], unpack(dead, j, math.min(j + 999, #dead)))
+    end
+    reaped = reaped + #dead
+  end
+end
+
+if redis.call('ZCARD', KEYS[1]) == 0 and redis.call('ZCARD', KEYS[2]) == 0 then
+  redis.call('SREM', KEYS[4], ARGV[2])
+  redis.call('DEL', KEYS[3])
+  return {reaped, 1}
+end
+return {reaped, 0}
+`)
+
+// CleanExpiredPeers 用 SSCAN 逐个分片增量清理超时节点
+//   - sweep.Lease > 0 时先获取（或续期）清理租约，被其他实例持有则跳过，多个实例不会重复扫描
+//   - 扫描进度保存在 Redis 中，预算用完后下次（或接手租约的实例）从同一位置继续
+func (r *Redis) CleanExpiredPeers(ctx context.Context, sweep *PeerSweep) (*SweepResult, error) {
+	if sweep.Lease > 0 {
+		acquired, err := cleanupLeaseScript.Run(ctx, r.Client, []string{cleanupLeaseKey}, r.owner, sweep.Lease.Milliseconds()).Bool()
+		if err != nil {
+			return nil, fmt.Errorf("failed to acquire cleanup lease: %w", err)
+		}
+		if !acquired {
+			return &SweepResult{Skipped: true}, nil
+		}
+	}
 
-		pipe := r.Client.Pipeline()
-		// 1. 抹权超时节点 (-inf 到 deathLine)
-		pipe.ZRemRangeByScore(ctx, seederKey, "-inf", deathLineStr)
-		pipe.ZRemRangeByScore(ctx, leecherKey, "-inf", deathLineStr)
+	state, err := r.Client.HMGet(ctx, cleanupCursorKey, "shard", "cursor").Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load cleanup cursor: %w", err)
+	}
+	shard, _ := strconv.Atoi(stringValue(state[0]))
+	cursor, _ := strconv.ParseUint(stringValue(state[1]), 10, 64)
+	if shard < 0 || shard >= activeShards {
+		shard, cursor = 0, 0
+	}
 
-		// 2. 查询余量
-		sCmd := pipe.ZCard(ctx, seederKey)
-		lCmd := pipe.ZCard(ctx, leecherKey)
+	start := time.Now()
+	// ZSet 分数即 Peer 的过期时间，早于当前时间的统统算死节点
+	now := strconv.FormatInt(start.Unix(), 10)
+	result := &SweepResult{}
+
+	for !result.Wrapped {
+		var infoHashes []string
+		shardKey := activeShardKey(shard)
+		infoHashes, cursor, err = r.Client.SScan(ctx, shardKey, cursor, "", cleanupScanCount).Result()
+		if err != nil {
+			err = fmt.Errorf("failed to scan active torrents: %w", err)
+			break
+		}
 
-		_, _ = pipe.Exec(ctx)
+		for _, infoHash := range infoHashes {
+			var reaped []int64
+			keys := []string{seedersKey(infoHash), leechersKey(infoHash), peerIDsKey(infoHash), shardKey}
+			reaped, err = reapScript.Run(ctx, r.Client, keys, now, infoHash).Int64Slice()
+			if err != nil {
+				err = fmt.Errorf("failed to reap torrent %s: %w", infoHash, err)
+				break
+			}
+			result.Scanned++
+			result.PeersReaped += reaped[0]
+			result.TorrentsReaped += reaped[1]
+		}
+		if err != nil {
+			break
+		}
 
-		// 3. 如果变成空城，果断将其从活跃列表中除名
-		if sCmd.Val() == 0 && lCmd.Val() == 0 {
-			r.Client.SRem(ctx, activeKey, infoHash)
+		// 当前分片扫完，进入下一个分片；回到第 0 个分片表示完成一整轮
+		if cursor == 0 {
+			shard = (shard + 1) % activeShards
+			result.Wrapped = shard == 0
+		}
+		if sweep.Budget > 0 && time.Since(start) >= sweep.Budget {
+			break
 		}
 	}
-	return nil
+
+	// 出错时也保存进度：游标只在整批处理完后前进，重复处理同一批是安全的
+	if saveErr := r.Client.HSet(ctx, cleanupCursorKey, "shard", shard, "cursor", cursor).Err(); saveErr != nil && err == nil {
+		err = fmt.Errorf("failed to save cleanup cursor: %w", saveErr)
+	}
+	return result, err
+}
+
+// stringValue HMGET 返回的字段值，不存在时为空字符串
+func stringValue(v interface{}) string {
+	str, _ := v.(string)
+	return str
+}
+
+// GetCachedPasskey 查询 passkey 缓存
+// 返回值: userID 为缓存的用户 ID（无效 passkey 缓存为空字符串）；found 表示缓存是否命中
+func (r *Redis) GetCachedPasskey(ctx context.Context, passkey string) (userID string, found bool, err error) {
+	key := fmt.Sprintf("tracker:passkey:%s", passkey)
+	val, err := r.Client.Get(ctx, key).Result()
+	if err == redis.Nil {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+	return val, true, nil
+}
+
+// CachePasskey 缓存 passkey 查询结果，userID 为空表示未知或已吊销（负缓存）
+func (r *Redis) CachePasskey(ctx context.Context, passkey, userID string, ttl time.Duration) error {
+	key := fmt.Sprintf("tracker:passkey:%s", passkey)
+	return r.Client.Set(ctx, key, userID, ttl).Err()
+}
+
+// InvalidatePasskey 删除 passkey 缓存（创建或吊销用户后调用，立即生效）
+func (r *Redis) InvalidatePasskey(ctx context.Context, passkey string) error {
+	key := fmt.Sprintf("tracker:passkey:%s", passkey)
+	return r.Client.Del(ctx, key).Err()
+}
+
+// peerSessionScript 原子地计算本次 announce 相对上次的上传/下载增量，并更新会话状态
+// KEYS[1]: 会话键
+// ARGV: uploaded, downloaded, started(0/1), stopped(0/1), ttl(秒)
+// 规则：
+//   - started 事件：客户端计数器从本次会话开始，增量即当前值
+//   - 已有会话：增量 = 当前值 - 上次值；当前值小于上次值视为客户端重启导致计数器归零，增量即当前值
+//   - 无会话且非 started（会话已过期）：无法确定基线，仅记录基线，增量为 0
+//   - stopped 事件：结算后删除会话
+var peerSessionScript = redis.NewScript(`
+local prev = redis.call('HMGET', KEYS[1], 'up', 'down')
+local up, down = tonumber(ARGV[1]), tonumber(ARGV[2])
+local du, dd = 0, 0
+if ARGV[3] == '1' then
+  du, dd = up, down
+elseif prev[1] then
+  local pu, pd = tonumber(prev[1]), tonumber(prev[2])
+  if up >= pu then du = up - pu else du = up end
+  if down >= pd then dd = down - pd else dd = down end
+end
+if ARGV[4] == '1' then
+  redis.call('DEL', KEYS[1])
+else
+  redis.call('HSET', KEYS[1], 'up', ARGV[1], 'down', ARGV[2])
+  redis.call('EXPIRE', KEYS[1], ARGV[5])
+end
+return {du, dd}
+`)
+
+// UpdatePeerSession 更新 Peer 会话并返回自上次 announce 以来的上传/下载增量
+// identity 为 Peer 的会话标识（key 参数或 peer_id），以 hex 形式放入键名
+func (r *Redis) UpdatePeerSession(ctx context.Context, infoHash, identity string, uploaded, downloaded int64, started, stopped bool, ttl time.Duration) (deltaUp, deltaDown int64, err error) {
+	key := sessionKey(infoHash, identity)
+
+	res, err := peerSessionScript.Run(ctx, r.Client, []string{key},
+		uploaded, downloaded, boolArg(started), boolArg(stopped), int64(ttl.Seconds())).Int64Slice()
+	if err != nil {
+		return 0, 0, err
+	}
+	return res[0], res[1], nil
+}
+
+// boolArg 将 bool 转为 Lua 脚本参数
+func boolArg(b bool) string {
+	if b {
+		return "1"
+	}
+	return "0"
+}
+
+// TorrentEventsChannel 种子登记/删除事件的 Pub/Sub 频道，各 Tracker 实例据此同步本地注册表
+const TorrentEventsChannel = "tracker:torrents:events"
+
+// 种子事件类型
+const (
+	TorrentEventPublish = "publish"
+	TorrentEventDelete  = "delete"
+)
+
+// TorrentEvent 种子登记/删除事件
+type TorrentEvent struct {
+	Action     string `json:"action"`
+	InfoHash   string `json:"info_hash"`
+	InfoHashV2 string `json:"info_hash_v2,omitempty"`
+	// PeerSelector 种子级 Peer 选取策略（仅 publish 事件）
+	PeerSelector string `json:"peer_selector,omitempty"`
+}
+
+// PublishTorrentEvent 广播种子事件
+func (r *Redis) PublishTorrentEvent(ctx context.Context, event TorrentEvent) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to encode torrent event: %w", err)
+	}
+	return r.Client.Publish(ctx, TorrentEventsChannel, payload).Err()
+}
+
+// SubscribeTorrentEvents 订阅种子事件（断线后 go-redis 会自动重新订阅）
+func (r *Redis) SubscribeTorrentEvents(ctx context.Context) *redis.PubSub {
+	return r.Client.Subscribe(ctx, TorrentEventsChannel)
+}
+
+// GetActiveTorrents 获取所有活跃种子的 info_hash（用于全量 scrape），合并全部分片
+func (r *Redis) GetActiveTorrents(ctx context.Context) ([]string, error) {
+	pipe := r.Client.Pipeline()
+	cmds := make([]*redis.StringSliceCmd, activeShards)
+	for shard := range cmds {
+		cmds[shard] = pipe.SMembers(ctx, activeShardKey(shard))
+	}
+	if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+		return nil, err
+	}
+
+	var infoHashes []string
+	for _, cmd := range cmds {
+		infoHashes = append(infoHashes, cmd.Val()...)
+	}
+	return infoHashes, nil
 }
 
 // UpdateStats 更新统计信息
 func (r *Redis) UpdateStats(ctx context.Context, infoHash string, seeders, leechers, completed int64) error {
-	key := fmt.Sprintf("tracker:stats:%s", infoHash)
+	key := statsKey(infoHash)
 
 	pipe := r.Client.Pipeline()
 	pipe.HSet(ctx, key, "seeders", seeders)
@@ -242,20 +808,70 @@ func (r *Redis) UpdateStats(ctx context.Context, infoHash string, seeders, leech
 
 // GetStats 获取统计信息
 func (r *Redis) GetStats(ctx context.Context, infoHash string) (map[string]string, error) {
-	key := fmt.Sprintf("tracker:stats:%s", infoHash)
+	key := statsKey(infoHash)
 	return r.Client.HGetAll(ctx, key).Result()
 }
 
 // IncrementCompleted 增加完成下载的计数
 func (r *Redis) IncrementCompleted(ctx context.Context, infoHash string) error {
-	key := fmt.Sprintf("tracker:stats:%s", infoHash)
+	key := statsKey(infoHash)
 	return r.Client.HIncrBy(ctx, key, "completed", 1).Err()
 }
 
+// GetCompleted 获取完成下载的计数（tracker:{sXX}:stats:{info_hash} 的 completed 字段）
+func (r *Redis) GetCompleted(ctx context.Context, infoHash string) (int64, error) {
+	key := statsKey(infoHash)
+	completed, err := r.Client.HGet(ctx, key, "completed").Int64()
+	if err == redis.Nil {
+		return 0, nil
+	}
+	return completed, err
+}
+
+// GetTorrentStats 在一个 Pipeline 中批量读取多个种子（含关联哈希）的做种 / 下载人数与完成次数
+func (r *Redis) GetTorrentStats(ctx context.Context, targets []StatsTarget) ([]models.TorrentStats, error) {
+	type hashCmds struct {
+		seeders, leechers *redis.IntCmd
+		completed         *redis.StringCmd
+	}
+
+	pipe := r.Client.Pipeline()
+	cmds := make([][]hashCmds, len(targets))
+	for i, target := range targets {
+		for _, hash := range append([]string{target.InfoHash}, target.Linked...) {
+			cmds[i] = append(cmds[i], hashCmds{
+				seeders:   pipe.ZCard(ctx, seedersKey(hash)),
+				leechers:  pipe.ZCard(ctx, leechersKey(hash)),
+				completed: pipe.HGet(ctx, statsKey(hash), "completed"),
+			})
+		}
+	}
+	if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+		return nil, err
+	}
+
+	stats := make([]models.TorrentStats, len(targets))
+	for i := range targets {
+		for _, c := range cmds[i] {
+			stats[i].Seeders += c.seeders.Val()
+			stats[i].Leechers += c.leechers.Val()
+			if n, err := c.completed.Int64(); err == nil {
+				stats[i].Completed += n
+			}
+		}
+	}
+	return stats, nil
+}
+
+// rateLimitKey 限流计数键
+func rateLimitKey(ip string) string {
+	return fmt.Sprintf("tracker:ratelimit:%s", ip)
+}
+
 // CheckRateLimit 检查指定 IP 的请求频率是否超过限制
 // 返回 true 表示允许请求，返回 false 表示限流
 func (r *Redis) CheckRateLimit(ctx context.Context, ip string, window time.Duration, limit int) (bool, error) {
-	key := fmt.Sprintf("tracker:ratelimit:%s", ip)
+	key := rateLimitKey(ip)
 
 	// 原子递增
 	count, err := r.Client.Incr(ctx, key).Result()
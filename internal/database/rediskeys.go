@@ -0,0 +1,484 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Redis 键设计（兼容 Redis Cluster）
+//
+// 同一种子的全部键带有相同的哈希标签 {sXX}，落在同一个 slot，Lua 脚本可以原子地操作它们：
+//
+//	tracker:{sXX}:seeders:{info_hash}              做种者 ZSet（分数为 Peer 过期时间）
+//	tracker:{sXX}:leechers:{info_hash}             下载者 ZSet
+//	tracker:{sXX}:peerids:{info_hash}              Peer 成员 -> peer_id
+//	tracker:{sXX}:identity:{info_hash}:{hex(id)}   Peer 身份（key 与已登记地址）
+//	tracker:{sXX}:session:{info_hash}:{hex(id)}    流量统计会话
+//	tracker:{sXX}:stats:{info_hash}                完成次数等统计
+//	tracker:{sXX}:active                           该分片中的活跃种子
+//
+// XX 为 info_hash 的 FNV-1a 哈希对 activeShards 取模（两位 hex）。活跃种子集合按同样的规则拆成 activeShards 个，
+// 与其中种子的键位于同一 slot，既能在脚本内原子地登记，也不会把全部种子集中在一个节点上。
+// 与种子无关的键（限流、passkey 缓存、v2 截断映射）各自只有一个键，不需要哈希标签。
+// 混合种子的 v1 与 v2 哈希可能位于不同 slot，合并两者的操作使用 Pipeline 而不是脚本
+
+// activeShards 活跃种子集合的分片数
+const activeShards = 256
+
+// torrentShard 返回种子所在的分片
+func torrentShard(infoHash string) int {
+	h := uint32(2166136261)
+	for i := 0; i < len(infoHash); i++ {
+		h ^= uint32(infoHash[i])
+		h *= 16777619
+	}
+	return int(h % activeShards)
+}
+
+// torrentKey 构造种子的键：tracker:{sXX}:kind:info_hash
+func torrentKey(kind, infoHash string) string {
+	return fmt.Sprintf("tracker:{s%02x}:%s:%s", torrentShard(infoHash), kind, infoHash)
+}
+
+func seedersKey(infoHash string) string  { return torrentKey("seeders", infoHash) }
+func leechersKey(infoHash string) string { return torrentKey("leechers", infoHash) }
+func peerIDsKey(infoHash string) string  { return torrentKey("peerids", infoHash) }
+func statsKey(infoHash string) string    { return torrentKey("stats", infoHash) }
+
+// identityKey Peer 身份键，peer_id 以 hex 形式放入键名
+func identityKey(infoHash, peerID string) string {
+	return fmt.Sprintf("%s:%x", torrentKey("identity", infoHash), peerID)
+}
+
+// sessionKey 流量统计会话键，identity（peer_id:key）以 hex 形式放入键名
+func sessionKey(infoHash, identity string) string {
+	return fmt.Sprintf("%s:%x", torrentKey("session", infoHash), identity)
+}
+
+// roleKey 返回做种者或下载者 ZSet 的键
+func roleKey(seeders bool, infoHash string) string {
+	if seeders {
+		return seedersKey(infoHash)
+	}
+	return leechersKey(infoHash)
+}
+
+// activeKey 返回种子所在分片的活跃种子集合
+func activeKey(infoHash string) string {
+	return activeShardKey(torrentShard(infoHash))
+}
+
+// activeShardKey 返回第 shard 个活跃种子集合
+func activeShardKey(shard int) string {
+	return fmt.Sprintf("tracker:{s%02x}:active", shard)
+}
+
+// torrentKeys 构造主 info_hash 及其关联 info_hash 的键列表
+func torrentKeys(key func(string) string, infoHash string, linked []string) []string {
+	keys := make([]string, 0, 1+len(linked))
+	keys = append(keys, key(infoHash))
+	for _, l := range linked {
+		keys = append(keys, key(l))
+	}
+	return keys
+}
+
+// scanKeys 遍历匹配 pattern 的键，Cluster 模式下遍历所有主节点（fn 可能被并发调用）
+func (r *Redis) scanKeys(ctx context.Context, pattern string, fn func(ctx context.Context, key string) error) error {
+	scan := func(ctx context.Context, client redis.UniversalClient) error {
+		iter := client.Scan(ctx, 0, pattern, 1000).Iterator()
+		for iter.Next(ctx) {
+			if err := fn(ctx, iter.Val()); err != nil {
+				return err
+			}
+		}
+		return iter.Err()
+	}
+
+	if cluster, ok := r.Client.(*redis.ClusterClient); ok {
+		return cluster.ForEachMaster(ctx, func(ctx context.Context, client *redis.Client) error {
+			return scan(ctx, client)
+		})
+	}
+	return scan(ctx, r.Client)
+}
+
+// PurgeTorrent 删除种子的全部键（Peer、身份、会话、统计）并移出活跃列表，用于测试与基准清理
+func (r *Redis) PurgeTorrent(ctx context.Context, infoHash string) error {
+	keys := []string{seedersKey(infoHash), leechersKey(infoHash), peerIDsKey(infoHash), statsKey(infoHash)}
+	for _, kind := range []string{"identity", "session"} {
+		err := r.scanKeys(ctx, torrentKey(kind, infoHash)+":*", func(ctx context.Context, key string) error {
+			return r.Client.Del(ctx, key).Err()
+		})
+		if err != nil {
+			return err
+		}
+	}
+	if err := r.Client.Del(ctx, keys...).Err(); err != nil {
+		return err
+	}
+	return r.Client.SRem(ctx, activeKey(infoHash), infoHash).Err()
+}
+
+// 旧版（不带哈希标签）的键
+const (
+	legacyActiveKey   = "tracker:active_torrents"
+	legacyStatsPrefix = "tracker:stats:"
+)
+
+// 旧键迁移的完成标记与统计迁移的认领
+const (
+	legacyKeysMarker   = "tracker:legacy_keys"
+	legacyKeysMigrated = "migrated"
+	legacyStatsLease   = time.Minute // 迁移统计期间持有清理租约的时长，每处理一个键续期一次
+)
+
+// errLegacyStatsLeaseLost 迁移统计期间清理租约被其他实例接手（本实例停顿超过 legacyStatsLease）
+var errLegacyStatsLeaseLost = errors.New("cleanup lease lost while migrating legacy stats")
+
+// MigrateLegacyKeys 将旧版键迁移到带哈希标签的新键，返回迁移的条目数（Swarm 与统计分别计数）
+//   - tracker:active_torrents 中的种子：做种者 / 下载者 ZSet 与 peer_id 哈希连同剩余 TTL 一起复制
+//   - tracker:stats:{info_hash}：完成次数累加到新键
+//
+// 旧的身份与会话记录不迁移，Peer 下次 announce 时重新建立（期间的流量增量按会话过期处理）。
+// 迁移逐键读取再写入，不依赖跨 slot 的 RENAME，Cluster 下也可用。
+// 种子在其键全部复制到新键后才移出 tracker:active_torrents，迁移中途退出时下次启动会重新复制（复制可重复执行）。
+// 完成后写入 tracker:legacy_keys，之后启动直接跳过，不再扫描整个键空间
+func (r *Redis) MigrateLegacyKeys(ctx context.Context) (int, error) {
+	marker, err := r.Client.Get(ctx, legacyKeysMarker).Result()
+	if err != nil && err != redis.Nil {
+		return 0, fmt.Errorf("failed to read legacy keys marker: %w", err)
+	}
+	if marker == legacyKeysMigrated {
+		return 0, nil
+	}
+
+	var migrated atomic.Int64
+	for {
+		infoHash, err := r.Client.SRandMember(ctx, legacyActiveKey).Result()
+		if err == redis.Nil {
+			break
+		}
+		if err != nil {
+			return int(migrated.Load()), fmt.Errorf("failed to read legacy active torrents: %w", err)
+		}
+		if err := r.migrateLegacySwarm(ctx, infoHash); err != nil {
+			return int(migrated.Load()), err
+		}
+		// 多个实例同时迁移同一个种子时只有一个实例移除成功并计入
+		n, err := r.Client.SRem(ctx, legacyActiveKey, infoHash).Result()
+		if err != nil {
+			return int(migrated.Load()), fmt.Errorf("failed to remove legacy active torrent: %w", err)
+		}
+		migrated.Add(n)
+	}
+
+	done, err := r.migrateLegacyStats(ctx, &migrated)
+	if err != nil {
+		return int(migrated.Load()), fmt.Errorf("failed to migrate legacy stats: %w", err)
+	}
+	if !done {
+		// 其他实例正在迁移统计，由它（或下次启动）写入完成标记
+		return int(migrated.Load()), nil
+	}
+
+	if err := r.Client.Set(ctx, legacyKeysMarker, legacyKeysMigrated, 0).Err(); err != nil {
+		return int(migrated.Load()), fmt.Errorf("failed to save legacy keys marker: %w", err)
+	}
+	return int(migrated.Load()), nil
+}
+
+// migrateLegacyStats 将旧版 tracker:stats:{info_hash} 的完成次数累加到新键，持有清理租约时才执行，返回是否已全部迁移
+// 每个旧键先 RENAME 为本实例的认领键（与旧键同一 slot），再累加到新键，成功后才删除认领键：
+//   - 累加失败或进程退出不会丢失完成次数，认领键留到下次迁移时由新的租约持有者接着处理
+//   - 同一时刻只有租约持有者迁移，RENAME 保证每个旧键只被认领一次，不会重复累加
+//
+// 累加成功后、删除认领键前退出时，该种子的完成次数会在下次迁移时再累加一次（只影响这一个键）
+func (r *Redis) migrateLegacyStats(ctx context.Context, migrated *atomic.Int64) (bool, error) {
+	renew := func() (bool, error) {
+		return cleanupLeaseScript.Run(ctx, r.Client, []string{cleanupLeaseKey}, r.owner, legacyStatsLease.Milliseconds()).Bool()
+	}
+	acquired, err := renew()
+	if err != nil {
+		return false, fmt.Errorf("failed to acquire cleanup lease: %w", err)
+	}
+	if !acquired {
+		return false, nil
+	}
+
+	migrate := func(ctx context.Context, key string) error {
+		if ok, err := renew(); err != nil || !ok {
+			if err == nil {
+				err = errLegacyStatsLeaseLost
+			}
+			return err
+		}
+
+		infoHash, claimed := legacyStatsClaimInfoHash(key)
+		if !claimed {
+			infoHash = strings.TrimPrefix(key, legacyStatsPrefix)
+		}
+		claim := legacyStatsClaimKey(infoHash, r.owner)
+		if key != claim {
+			if err := r.Client.Rename(ctx, key, claim).Err(); err != nil {
+				if strings.Contains(err.Error(), "no such key") {
+					return nil
+				}
+				return err
+			}
+		}
+
+		completed, err := r.Client.HGet(ctx, claim, "completed").Int64()
+		if err != nil && err != redis.Nil {
+			return err
+		}
+		if completed > 0 {
+			if err := r.Client.HIncrBy(ctx, statsKey(infoHash), "completed", completed).Err(); err != nil {
+				return err
+			}
+		}
+		if err := r.Client.Del(ctx, claim).Err(); err != nil {
+			return err
+		}
+		migrated.Add(1)
+		return nil
+	}
+
+	// 先处理之前的租约持有者中途退出时留下的认领键，再迁移旧键
+	if err := r.scanKeys(ctx, "{"+legacyStatsPrefix+"*}:claim:*", migrate); err != nil {
+		return false, err
+	}
+	if err := r.scanKeys(ctx, legacyStatsPrefix+"*", migrate); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// legacyStatsClaimKey 迁移统计时认领旧键的键名，哈希标签为旧键名，与旧键位于同一 slot（RENAME 在 Cluster 下可用）
+func legacyStatsClaimKey(infoHash, owner string) string {
+	return "{" + legacyStatsPrefix + infoHash + "}:claim:" + owner
+}
+
+// legacyStatsClaimInfoHash 从认领键中取出 info_hash，不是认领键时 ok 为 false
+func legacyStatsClaimInfoHash(key string) (infoHash string, ok bool) {
+	rest, ok := strings.CutPrefix(key, "{"+legacyStatsPrefix)
+	if !ok {
+		return "", false
+	}
+	infoHash, _, ok = strings.Cut(rest, "}:claim:")
+	return infoHash, ok
+}
+
+// migrateLegacySwarm 复制单个种子的旧版 Peer 键并删除旧键，种子登记到新的活跃列表
+// 每个旧键复制成功后才删除，中途失败时重新执行不会丢失 Peer
+func (r *Redis) migrateLegacySwarm(ctx context.Context, infoHash string) error {
+	pairs := []struct{ legacy, current string }{
+		{"tracker:seeders:" + infoHash, seedersKey(infoHash)},
+		{"tracker:leechers:" + infoHash, leechersKey(infoHash)},
+	}
+	for _, p := range pairs {
+		members, err := r.Client.ZRangeWithScores(ctx, p.legacy, 0, -1).Result()
+		if err != nil {
+			return fmt.Errorf("failed to read legacy key %s: %w", p.legacy, err)
+		}
+		if len(members) > 0 {
+			if err := r.Client.ZAdd(ctx, p.current, members...).Err(); err != nil {
+				return err
+			}
+			r.copyTTL(ctx, p.legacy, p.current)
+		}
+		r.Client.Del(ctx, p.legacy)
+	}
+
+	legacyPeerIDs := "tracker:peerids:" + infoHash
+	peerIDs, err := r.Client.HGetAll(ctx, legacyPeerIDs).Result()
+	if err != nil {
+		return fmt.Errorf("failed to read legacy key %s: %w", legacyPeerIDs, err)
+	}
+	if len(peerIDs) > 0 {
+		if err := r.Client.HSet(ctx, peerIDsKey(infoHash), peerIDs).Err(); err != nil {
+			return err
+		}
+		r.copyTTL(ctx, legacyPeerIDs, peerIDsKey(infoHash))
+	}
+	r.Client.Del(ctx, legacyPeerIDs)
+
+	return r.Client.SAdd(ctx, activeKey(infoHash), infoHash).Err()
+}
+
+// copyTTL 将旧键的剩余 TTL 设置到新键
+func (r *Redis) copyTTL(ctx context.Context, from, to string) {
+	if ttl, err := r.Client.PTTL(ctx, from).Result(); err == nil && ttl > 0 {
+		r.Client.PExpire(ctx, to, ttl)
+	}
+}
+
+// Swarm 中 Peer 成员的存储格式标记，迁移到 Compact 二进制格式（peeraddr.go）后写入
+const (
+	peerEncodingKey     = "tracker:peer_encoding"
+	peerEncodingCompact = "compact"
+)
+
+// MigratePeerEncoding 将旧版 "IP:Port" 文本格式的 Peer 成员转换为 Compact 二进制存储格式，返回转换的条目数
+//   - 活跃种子的做种者 / 下载者 ZSet 成员（保留分数与 TTL）和 peer_id 哈希字段
+//   - 身份记录中的地址列表
+//
+// 完成后写入 tracker:peer_encoding，之后启动直接跳过。只转换能解析为文本地址的成员，重复执行是安全的；
+// 新格式成员已存在时保留（ZADD NX / HSETNX），不会用旧分数覆盖迁移期间新 announce 的心跳
+func (r *Redis) MigratePeerEncoding(ctx context.Context) (int, error) {
+	encoding, err := r.Client.Get(ctx, peerEncodingKey).Result()
+	if err != nil && err != redis.Nil {
+		return 0, fmt.Errorf("failed to read peer encoding: %w", err)
+	}
+	if encoding == peerEncodingCompact {
+		return 0, nil
+	}
+
+	var migrated atomic.Int64
+	for shard := 0; shard < activeShards; shard++ {
+		infoHashes, err := r.Client.SMembers(ctx, activeShardKey(shard)).Result()
+		if err != nil {
+			return int(migrated.Load()), fmt.Errorf("failed to list active torrents: %w", err)
+		}
+		for _, infoHash := range infoHashes {
+			n, err := r.migrateSwarmEncoding(ctx, infoHash)
+			migrated.Add(int64(n))
+			if err != nil {
+				return int(migrated.Load()), fmt.Errorf("failed to migrate peers of %s: %w", infoHash, err)
+			}
+		}
+	}
+
+	err = r.scanKeys(ctx, "tracker:{s??}:identity:*", func(ctx context.Context, key string) error {
+		addrs, err := r.Client.HGet(ctx, key, "addrs").Result()
+		if err == redis.Nil {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if encoded, ok := textIdentityAddrs(addrs); ok {
+			if err := r.Client.HSet(ctx, key, "addrs", encoded).Err(); err != nil {
+				return err
+			}
+			migrated.Add(1)
+		}
+		return nil
+	})
+	if err != nil {
+		return int(migrated.Load()), fmt.Errorf("failed to migrate identity addresses: %w", err)
+	}
+
+	if err := r.Client.Set(ctx, peerEncodingKey, peerEncodingCompact, 0).Err(); err != nil {
+		return int(migrated.Load()), fmt.Errorf("failed to save peer encoding: %w", err)
+	}
+	return int(migrated.Load()), nil
+}
+
+// migrateSwarmEncoding 转换单个种子的 ZSet 成员与 peer_id 哈希字段，返回转换的成员数
+func (r *Redis) migrateSwarmEncoding(ctx context.Context, infoHash string) (int, error) {
+	migrated := 0
+	for _, key := range []string{seedersKey(infoHash), leechersKey(infoHash)} {
+		entries, err := r.Client.ZRangeWithScores(ctx, key, 0, -1).Result()
+		if err != nil {
+			return migrated, err
+		}
+
+		pipe := r.Client.Pipeline()
+		for _, z := range entries {
+			text, _ := z.Member.(string)
+			member, ok := textPeerMember(text)
+			if !ok {
+				continue
+			}
+			pipe.ZAddNX(ctx, key, redis.Z{Score: z.Score, Member: member})
+			pipe.ZRem(ctx, key, text)
+			migrated++
+		}
+		if pipe.Len() > 0 {
+			if _, err := pipe.Exec(ctx); err != nil {
+				return migrated, err
+			}
+		}
+	}
+
+	peerIDs, err := r.Client.HGetAll(ctx, peerIDsKey(infoHash)).Result()
+	if err != nil {
+		return migrated, err
+	}
+	pipe := r.Client.Pipeline()
+	for text, peerID := range peerIDs {
+		if member, ok := textPeerMember(text); ok {
+			pipe.HSetNX(ctx, peerIDsKey(infoHash), member, peerID)
+			pipe.HDel(ctx, peerIDsKey(infoHash), text)
+		}
+	}
+	if pipe.Len() > 0 {
+		if _, err := pipe.Exec(ctx); err != nil {
+			return migrated, err
+		}
+	}
+	return migrated, nil
+}
+
+// Swarm 中 ZSet 分数的语义标记，从最近心跳时间改为 Peer 过期时间后写入
+const (
+	peerScoreKey      = "tracker:peer_score"
+	peerScoreDeadline = "deadline"
+)
+
+// deadlineScoreScript 将种子中仍为心跳时间的分数（不晚于当前时间）加上旧版超时，转换为过期时间，返回转换的成员数
+// KEYS: seeders, leechers（同属一个 slot）
+// ARGV: now(Unix 秒), timeout(秒)
+var deadlineScoreScript = redis.NewScript(`
+local converted = 0
+for i = 1, 2 do
+  local entries = redis.call('ZRANGEBYSCORE', KEYS[i], '-inf', ARGV[1], 'WITHSCORES')
+  for j = 1, #entries, 2 do
+    redis.call('ZADD', KEYS[i], 'XX', tonumber(entries[j + 1]) + tonumber(ARGV[2]), entries[j])
+    converted = converted + 1
+  end
+end
+return converted
+`)
+
+// MigratePeerDeadlines 将活跃种子做种者 / 下载者 ZSet 的分数从最近心跳时间转换为过期时间（心跳时间 + timeout），返回转换的成员数
+// timeout 应为旧版的死亡判定时长（2 倍心跳间隔上限），转换后的 Peer 与旧版在同一时刻被清理。
+// 完成后写入 tracker:peer_score，之后启动直接跳过。新版写入的分数晚于当前时间，不会被重复转换；
+// 旧版实例在标记写入后登记的 Peer 会被立即清理，因此升级需要先停止全部旧实例
+func (r *Redis) MigratePeerDeadlines(ctx context.Context, timeout time.Duration) (int, error) {
+	score, err := r.Client.Get(ctx, peerScoreKey).Result()
+	if err != nil && err != redis.Nil {
+		return 0, fmt.Errorf("failed to read peer score mode: %w", err)
+	}
+	if score == peerScoreDeadline {
+		return 0, nil
+	}
+
+	migrated := 0
+	for shard := 0; shard < activeShards; shard++ {
+		infoHashes, err := r.Client.SMembers(ctx, activeShardKey(shard)).Result()
+		if err != nil {
+			return migrated, fmt.Errorf("failed to list active torrents: %w", err)
+		}
+		for _, infoHash := range infoHashes {
+			keys := []string{seedersKey(infoHash), leechersKey(infoHash)}
+			n, err := deadlineScoreScript.Run(ctx, r.Client, keys, time.Now().Unix(), int64(timeout.Seconds())).Int()
+			if err != nil {
+				return migrated, fmt.Errorf("failed to migrate peer scores of %s: %w", infoHash, err)
+			}
+			migrated += n
+		}
+	}
+
+	if err := r.Client.Set(ctx, peerScoreKey, peerScoreDeadline, 0).Err(); err != nil {
+		return migrated, fmt.Errorf("failed to save peer score mode: %w", err)
+	}
+	return migrated, nil
+}
@@ -8,14 +8,16 @@ import (
 
 // Torrent MongoDB 中的 Torrent 模型
 type Torrent struct {
-	ID          primitive.ObjectID `bson:"_id,omitempty" json:"id"`
-	Name        string             `bson:"name" json:"name"`                 // 模型名称
-	InfoHash    string             `bson:"info_hash" json:"info_hash"`       // 种子唯一指纹
-	TotalSize   int64              `bson:"total_size" json:"total_size"`     // 总大小（字节）
-	FileCount   int                `bson:"file_count" json:"file_count"`     // 文件数量
-	MagnetLink  string             `bson:"magnet_link" json:"magnet_link"`   // 磁力链接
-	PieceLength int64              `bson:"piece_length" json:"piece_length"` // 分片大小
-	CreatedAt   time.Time          `bson:"created_at" json:"created_at"`     // 创建时间
+	ID           primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Name         string             `bson:"name" json:"name"`                                       // 模型名称
+	InfoHash     string             `bson:"info_hash" json:"info_hash"`                             // 种子唯一指纹（v1，40 字符 hex）
+	InfoHashV2   string             `bson:"info_hash_v2,omitempty" json:"info_hash_v2,omitempty"`   // v2 指纹（BEP-0052，64 字符 hex），混合种子同时填写两者
+	TotalSize    int64              `bson:"total_size" json:"total_size"`                           // 总大小（字节）
+	FileCount    int                `bson:"file_count" json:"file_count"`                           // 文件数量
+	MagnetLink   string             `bson:"magnet_link" json:"magnet_link"`                         // 磁力链接
+	PieceLength  int64              `bson:"piece_length" json:"piece_length"`                       // 分片大小
+	PeerSelector string             `bson:"peer_selector,omitempty" json:"peer_selector,omitempty"` // Peer 选取策略（覆盖 PEER_SELECTOR，为空时使用默认）
+	CreatedAt    time.Time          `bson:"created_at" json:"created_at"`                           // 创建时间
 }
 
 // TorrentStats Tracker 统计信息（从 Redis 获取）
@@ -25,6 +27,14 @@ type TorrentStats struct {
 	Completed int64 `json:"completed"` // 完成下载次数
 }
 
+// TorrentTraffic 种子累计流量（MongoDB torrent_traffic 集合，来自 announce 增量）
+type TorrentTraffic struct {
+	InfoHash   string    `bson:"info_hash" json:"info_hash"`
+	Uploaded   int64     `bson:"uploaded" json:"uploaded"`     // Swarm 累计上传字节数
+	Downloaded int64     `bson:"downloaded" json:"downloaded"` // Swarm 累计下载字节数
+	UpdatedAt  time.Time `bson:"updated_at" json:"updated_at"`
+}
+
 // TorrentWithStats 带统计信息的 Torrent
 type TorrentWithStats struct {
 	Torrent
@@ -32,13 +42,14 @@ type TorrentWithStats struct {
 }
 
 // PeerInfo Peer 信息
+// bencode 标签对应非 Compact 模式下 peers 列表中的字典（BEP-0003）
 type PeerInfo struct {
-	IP         string `json:"ip"`
-	Port       int    `json:"port"`
-	PeerID     string `json:"peer_id,omitempty"`
-	Uploaded   int64  `json:"uploaded,omitempty"`
-	Downloaded int64  `json:"downloaded,omitempty"`
-	Left       int64  `json:"left,omitempty"`
+	IP         string `json:"ip" bencode:"ip"`
+	Port       int    `json:"port" bencode:"port"`
+	PeerID     string `json:"peer_id,omitempty" bencode:"peer id,omitempty"`
+	Uploaded   int64  `json:"uploaded,omitempty" bencode:"-"`
+	Downloaded int64  `json:"downloaded,omitempty" bencode:"-"`
+	Left       int64  `json:"left,omitempty" bencode:"-"`
 }
 
 // AnnounceRequest Tracker announce 请求参数
@@ -51,14 +62,20 @@ type AnnounceRequest struct {
 	Left       int64  `json:"left"`       // 剩余字节数
 	Event      string `json:"event"`      // 事件: started, completed, stopped
 	Compact    int    `json:"compact"`    // 是否使用紧凑模式
+	NoPeerID   int    `json:"no_peer_id"` // 非 Compact 模式下是否省略 peer id
 	NumWant    int    `json:"numwant"`    // 期望返回的 peer 数量
+	Key        string `json:"key"`        // 客户端生成的随机标识（可选），用于跨 IP 识别同一 Peer
+	Passkey    string `json:"-"`          // 私有 Tracker 密钥（来自 /announce/{passkey}）
 }
 
 // AnnounceResponse Tracker announce 响应
+// 可直接通过 bencode.Marshal 编码为非 Compact 模式的响应
 type AnnounceResponse struct {
-	Interval    int64      `json:"interval"`     // 心跳间隔（秒）
-	MinInterval int64      `json:"min_interval"` // 最小心跳间隔（秒）
-	Complete    int64      `json:"complete"`     // Seeders 数量
-	Incomplete  int64      `json:"incomplete"`   // Leechers 数量
-	Peers       []PeerInfo `json:"peers"`        // Peer 列表
+	Interval    int64      `json:"interval" bencode:"interval"`                           // 心跳间隔（秒）
+	MinInterval int64      `json:"min_interval" bencode:"min interval"`                   // 最小心跳间隔（秒）
+	Complete    int64      `json:"complete" bencode:"complete"`                           // Seeders 数量
+	ExternalIP  []byte     `json:"external_ip,omitempty" bencode:"external ip,omitempty"` // 客户端公网地址（BEP-0024，4 或 16 字节）
+	Incomplete  int64      `json:"incomplete" bencode:"incomplete"`                       // Leechers 数量
+	Peers       []PeerInfo `json:"peers" bencode:"peers"`                                 // Peer 列表
+	Peers6      []PeerInfo `json:"peers6,omitempty" bencode:"peers6,omitempty"`           // IPv6 Peer 列表（BEP-0007）
 }
@@ -0,0 +1,19 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// User 私有 Tracker 用户（MongoDB users 集合）
+type User struct {
+	ID         primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Name       string             `bson:"name" json:"name"`                                 // 用户名
+	Passkey    string             `bson:"passkey" json:"-"`                                 // Announce URL 中的密钥（32 字符 hex）
+	Revoked    bool               `bson:"revoked" json:"revoked"`                           // 是否已吊销
+	CreatedAt  time.Time          `bson:"created_at" json:"created_at"`                     // 创建时间
+	RevokedAt  *time.Time         `bson:"revoked_at,omitempty" json:"revoked_at,omitempty"` // 吊销时间
+	Uploaded   int64              `bson:"uploaded" json:"uploaded"`                         // 累计上传字节数（来自 announce 增量）
+	Downloaded int64              `bson:"downloaded" json:"downloaded"`                     // 累计下载字节数（来自 announce 增量）
+}
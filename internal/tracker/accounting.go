@@ -0,0 +1,132 @@
+package tracker
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"llmpt/internal/database"
+	"llmpt/internal/models"
+)
+
+// 上传/下载流量统计
+// 每次 announce 根据 Peer 会话（info_hash + key 或 peer_id）计算增量，
+// 增量先在内存中按用户和种子聚合，再定期批量写入 MongoDB，避免每次 announce 都写库
+
+// Accountant 流量增量聚合器
+type Accountant struct {
+	db *database.DB
+
+	mu       sync.Mutex
+	users    map[string]database.Traffic // 用户 ID -> 待写入增量
+	torrents map[string]database.Traffic // info_hash -> 待写入增量
+}
+
+// NewAccountant 创建流量聚合器
+func NewAccountant(db *database.DB) *Accountant {
+	return &Accountant{
+		db:       db,
+		users:    make(map[string]database.Traffic),
+		torrents: make(map[string]database.Traffic),
+	}
+}
+
+// Record 记录一次增量；userID 为空（匿名 announce）时只计入种子统计
+func (a *Accountant) Record(userID, infoHash string, uploaded, downloaded int64) {
+	if uploaded == 0 && downloaded == 0 {
+		return
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if userID != "" {
+		t := a.users[userID]
+		t.Uploaded += uploaded
+		t.Downloaded += downloaded
+		a.users[userID] = t
+	}
+
+	t := a.torrents[infoHash]
+	t.Uploaded += uploaded
+	t.Downloaded += downloaded
+	a.torrents[infoHash] = t
+}
+
+// Flush 将已聚合的增量写入 MongoDB
+// 写入失败时把增量合并回内存，下次重试，不丢数据
+func (a *Accountant) Flush(ctx context.Context) error {
+	a.mu.Lock()
+	users, torrents := a.users, a.torrents
+	a.users = make(map[string]database.Traffic)
+	a.torrents = make(map[string]database.Traffic)
+	a.mu.Unlock()
+
+	if len(users) == 0 && len(torrents) == 0 {
+		return nil
+	}
+
+	if err := a.db.MongoDB.AddTraffic(ctx, users, torrents); err != nil {
+		a.mu.Lock()
+		for id, t := range users {
+			merged := a.users[id]
+			merged.Uploaded += t.Uploaded
+			merged.Downloaded += t.Downloaded
+			a.users[id] = merged
+		}
+		for hash, t := range torrents {
+			merged := a.torrents[hash]
+			merged.Uploaded += t.Uploaded
+			merged.Downloaded += t.Downloaded
+			a.torrents[hash] = merged
+		}
+		a.mu.Unlock()
+		return err
+	}
+	return nil
+}
+
+// StartFlush 定期将增量写入 MongoDB，ctx 取消时做最后一次写入
+func (a *Accountant) StartFlush(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			// 使用独立的 context 完成最后一次写入
+			flushCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			if err := a.Flush(flushCtx); err != nil {
+				fmt.Printf("[accounting] final flush failed: %v\n", err)
+			}
+			cancel()
+			return
+		case <-ticker.C:
+			if err := a.Flush(ctx); err != nil {
+				fmt.Printf("[accounting] failed to flush traffic: %v\n", err)
+			}
+		}
+	}
+}
+
+// Accountant 返回处理器使用的流量聚合器
+func (h *Handler) Accountant() *Accountant {
+	return h.accountant
+}
+
+// accountTraffic 计算本次 announce 的流量增量并记入聚合器
+// 会话以 peer_id + key 标识（与 Peer 身份一致），不同 Peer 即使携带相同的 key 也不会共用计数基线；
+// 存活时间 ttl 与 Peer 的过期时间一致
+func (h *Handler) accountTraffic(ctx context.Context, req *models.AnnounceRequest, userID string, ttl time.Duration) {
+	identity := req.PeerID + ":" + req.Key
+
+	deltaUp, deltaDown, err := h.peers.UpdatePeerSession(ctx, req.InfoHash, identity,
+		req.Uploaded, req.Downloaded, req.Event == "started", req.Event == "stopped", ttl)
+	if err != nil {
+		fmt.Printf("[accounting] failed to update peer session: %v\n", err)
+		return
+	}
+
+	h.accountant.Record(userID, req.InfoHash, deltaUp, deltaDown)
+}
@@ -2,12 +2,12 @@ package tracker
 
 import (
 	"context"
-	"encoding/hex"
+	"errors"
 	"fmt"
-	"net"
 	"net/http"
+	"net/netip"
+	"slices"
 	"strconv"
-	"strings"
 	"time"
 
 	"llmpt/internal/config"
@@ -17,19 +17,35 @@ import (
 
 // Handler Tracker HTTP 处理器
 type Handler struct {
-	db     *database.DB
-	config *config.Config
+	db         *database.DB
+	peers      database.PeerStore
+	config     *config.Config
+	registry   *Registry
+	accountant *Accountant
+	selectors  map[string]PeerSelector
+	geoip      *GeoIP
+	load       loadMonitor
+	cleanup    cleanupMonitor
+	stats      statsCache
+	swarmSizes swarmSizeCache
 }
 
 // NewHandler 创建 Tracker 处理器
 func NewHandler(db *database.DB, cfg *config.Config) *Handler {
+	geoip := NewGeoIP(cfg.Server.GeoIPDatabases)
 	return &Handler{
-		db:     db,
-		config: cfg,
+		db:         db,
+		peers:      db.Peers,
+		config:     cfg,
+		registry:   NewRegistry(db),
+		accountant: NewAccountant(db),
+		selectors:  newPeerSelectors(db.Peers, cfg, geoip),
+		geoip:      geoip,
 	}
 }
 
 // Announce 处理 /announce 请求（BEP-0003 核心接口）
+// 私有 Tracker 使用 /announce/{passkey} 形式的个人 URL
 // GET /announce?info_hash=...&peer_id=...&port=...&uploaded=...&downloaded=...&left=...&event=...&compact=1
 func (h *Handler) Announce(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
@@ -40,75 +56,202 @@ func (h *Handler) Announce(w http.ResponseWriter, r *http.Request) {
 		h.sendError(w, fmt.Sprintf("invalid request: %v", err))
 		return
 	}
+	req.Passkey = r.PathValue("passkey")
 
-	// 获取客户端 IP
-	clientIP := getClientIP(r)
+	// 获取客户端 IP（双栈客户端可通过 ipv4= / ipv6= 同时登记两个地址，BEP-0007）
+	observed := h.observedClientIP(r)
+	clientIPs := h.announceIPs(r, observed)
 
-	// 检查请求频率限制 (Rate Limit)
-	allowed, err := h.db.Redis.CheckRateLimit(ctx, clientIP, h.config.Server.RateLimitWindow, h.config.Server.RateLimitBurst)
+	result, err := h.processAnnounce(ctx, req, clientIPs)
 	if err != nil {
-		fmt.Printf("[announce] ratelimit err: %v\n", err)
-		h.sendError(w, "internal server error")
+		h.sendError(w, err.Error())
 		return
 	}
+
+	// 非 Compact 模式需要返回每个 Peer 的 peer id（客户端传 no_peer_id=1 时省略）
+	var peerIDs map[string]string
+	if req.Compact != 1 && req.NoPeerID != 1 {
+		peerIDs, err = h.peers.GetPeerIDs(ctx, req.InfoHash, result.Peers, h.linkedInfoHashes(req.InfoHash)...)
+		if err != nil {
+			fmt.Printf("[announce] failed to get peer ids: %v\n", err)
+		}
+	}
+
+	// 发送响应（external ip 为 Tracker 实际看到的地址，BEP-0024）
+	h.sendSuccess(w, req, result, peerIDs, observed)
+}
+
+// errRateLimited 超过 announce 频率限制时的 failure reason（BT 协议标准做法：返回 failure reason）
+var errRateLimited = fmt.Errorf("Too many requests. Please slow down.")
+
+// checkRateLimit 按客户端 IP 单独检查一次频率限制（RATE_LIMIT_WINDOW / RATE_LIMIT_BURST，与 announce 共用计数），
+// 用于没有与 Peer 写入合并的请求；tag 为日志前缀，返回的 error 可直接作为 failure reason
+func (h *Handler) checkRateLimit(ctx context.Context, tag, clientIP string) error {
+	allowed, err := h.peers.CheckRateLimit(ctx, clientIP, h.config.Server.RateLimitWindow, h.config.Server.RateLimitBurst)
+	if err != nil {
+		fmt.Printf("[%s] ratelimit err: %v\n", tag, err)
+		return fmt.Errorf("internal server error")
+	}
 	if !allowed {
-		fmt.Printf("[announce] ratelimit exceeded IP: %s\n", clientIP)
-		// BT协议标准做法：返回failure reason
-		h.sendError(w, "Too many requests. Please slow down.")
-		return
+		fmt.Printf("[%s] ratelimit exceeded IP: %s\n", tag, clientIP)
+		return errRateLimited
 	}
+	return nil
+}
 
-	// 构建 Peer 标识
-	// 必须使用 net.JoinHostPort，它会自动给 IPv6 地址加方括号
-	// IPv4: "192.168.1.100:6881"
-	// IPv6: "[2402:4e00:1820:400:...]:6881"
-	peer := net.JoinHostPort(clientIP, strconv.Itoa(req.Port))
-
-	fmt.Printf("[announce] peer_id=%s ip=%s peer=%s event=%s left=%d\n",
-		req.PeerID, clientIP, peer, req.Event, req.Left)
-
-	// 处理不同事件
-	switch req.Event {
-	case "stopped":
-		// 客户端停止，移除 Peer
-		if err := h.db.Redis.RemovePeer(ctx, req.InfoHash, peer); err != nil {
-			fmt.Printf("failed to remove peer: %v\n", err)
-		}
-		// 重新计算统计
-		seeders, leechers := h.countStats(ctx, req.InfoHash)
-		h.sendSuccess(w, req, []string{}, seeders, leechers)
-		return
+// errPeerKeyMismatch peer_id 已由携带其他 key 的客户端登记时的 failure reason
+var errPeerKeyMismatch = fmt.Errorf("peer_id is already registered with a different key")
+
+// announceResult 协议无关的 Announce 处理结果（HTTP 与 UDP 共用）
+type announceResult struct {
+	Peers       []string // 返回给客户端的 Peer 列表（存储格式，见 database.PeerMember，已排除自己）
+	Seeders     int64
+	Leechers    int64
+	Interval    time.Duration // 按 Swarm 规模和负载计算的心跳间隔
+	MinInterval time.Duration
+}
+
+// processAnnounce 执行 Announce 的核心逻辑：限流、认证、事件处理、Peer 写入与选取
+// HTTP 和 UDP 两条入口都走这里，保证两类客户端落在同一个 Swarm 中
+// clientIPs[0] 为主地址（用于限流和日志），其余为同一 Peer 的其他地址族地址，均以同一端口登记
+// 返回的 error 文本会直接作为 failure reason 发给客户端
+func (h *Handler) processAnnounce(ctx context.Context, req *models.AnnounceRequest, clientIPs []string) (*announceResult, error) {
+	clientIP := clientIPs[0]
+	h.load.observe()
+
+	// 截断形式的 v2 哈希解析到完整 v2 命名空间（BEP-0052）
+	req.InfoHash = h.resolveInfoHash(req.InfoHash)
+
+	// 白名单模式：只接受已登记的种子，避免被当作公共 Tracker 滥用（查本地注册表，不访问 MongoDB）
+	if !h.allowTorrent(req.InfoHash) {
+		return nil, errUnregisteredTorrent
+	}
+
+	// 客户端白名单 / 黑名单（按 peer_id 前缀）
+	if err := h.checkClient(req.PeerID); err != nil {
+		fmt.Printf("[announce] rejected client: peer_id=%q ip=%s: %v\n", req.PeerID, clientIP, err)
+		return nil, err
+	}
 
-	case "completed":
-		// 下载完成，增加完成计数
-		if err := h.db.Redis.IncrementCompleted(ctx, req.InfoHash); err != nil {
-			fmt.Printf("failed to increment completed: %v\n", err)
+	// 私有 Tracker：passkey 认证前单独限流，避免被用于暴力枚举 passkey；
+	// 其余请求的限流与 Peer 写入在同一次存储调用中完成
+	if req.Passkey != "" {
+		if err := h.checkRateLimit(ctx, "announce", clientIP); err != nil {
+			return nil, err
 		}
 	}
 
-	// 更新 Peer 信息（使用配置中的 TTL）
-	isSeeder := req.Left == 0
-	if err := h.db.Redis.AddPeer(ctx, req.InfoHash, peer, isSeeder, h.config.Server.AnnounceInterval); err != nil {
-		h.sendError(w, fmt.Sprintf("failed to add peer: %v", err))
-		return
+	// 私有 Tracker passkey 认证（放在限流之后，避免被用于暴力枚举 passkey）
+	userID, err := h.authenticate(ctx, req.Passkey)
+	if err != nil {
+		return nil, err
 	}
 
+	// 构建 Peer 标识：存储格式为 Compact 二进制（IPv4 6 字节、IPv6 18 字节），见 database.PeerMember
+	ownAddrs := make([]netip.Addr, 0, len(clientIPs))
+	ownPeers := make([]string, 0, len(clientIPs))
+	for _, ip := range clientIPs {
+		if addr, err := netip.ParseAddr(ip); err == nil {
+			ownAddrs = append(ownAddrs, addr)
+			ownPeers = append(ownPeers, peerMember(addr, req.Port))
+		}
+	}
+
+	fmt.Printf("[announce] peer_id=%s ip=%s addrs=%v port=%d event=%s left=%d\n",
+		req.PeerID, clientIP, ownAddrs, req.Port, req.Event, req.Left)
+
 	// 获取其他 Peer（排除自己）
 	numWant := req.NumWant
-	if numWant == 0 || numWant > 50 {
+	if numWant <= 0 || numWant > 50 {
 		numWant = 50 // 默认返回 50 个
 	}
 
-	peers, err := h.db.Redis.GetPeersForRequest(ctx, req.InfoHash, int64(numWant+1), isSeeder) // 多取 1 个，用于排除自己
-	if err != nil {
-		h.sendError(w, fmt.Sprintf("failed to get peers: %v", err))
-		return
+	// 混合种子（v1 + v2）的另一半哈希与本哈希共享 Peer 池
+	linked := h.linkedInfoHashes(req.InfoHash)
+	selector := h.peerSelector(req.InfoHash)
+
+	// interval 决定 Peer 的过期时间，在写入前按该 Swarm 上一次 announce 后的规模计算
+	interval, minInterval := h.announceInterval(h.swarmSizes.get(req.InfoHash))
+	ttl := peerTTL(interval)
+
+	// 限流、事件处理（stopped 移除 Peer、completed 计数）、Peer 写入与人数统计在一次存储调用中完成；
+	// 默认的 random 策略由存储顺带采样，其他策略在之后单独选取
+	// Peer 以 (info_hash, peer_id, key) 为身份，IP / 端口变化时旧地址被原子替换；
+	// Peer 在本次下发的 interval 的 1.5 倍后过期，之前没有再次 announce 即由清理任务移除
+	isSeeder := req.Left == 0
+	op := &database.PeerAnnounce{
+		InfoHash:  req.InfoHash,
+		Linked:    linked,
+		Peers:     ownPeers,
+		PeerID:    req.PeerID,
+		Key:       req.Key,
+		Seeder:    isSeeder,
+		Stopped:   req.Event == "stopped",
+		Completed: req.Event == "completed",
+		TTL:       ttl,
+	}
+	if req.Passkey == "" {
+		op.RateLimitIP = clientIP
+		op.RateLimitWindow = h.config.Server.RateLimitWindow
+		op.RateLimitBurst = h.config.Server.RateLimitBurst
+	}
+	_, storeSamples := selector.(*randomSelector)
+	if storeSamples && !op.Stopped {
+		// 多取 len(ownPeers) 个，用于排除自己
+		op.NumWant = numWant + len(ownPeers)
+	}
+
+	stored, err := h.peers.Announce(ctx, op)
+	switch {
+	case errors.Is(err, database.ErrRateLimited):
+		fmt.Printf("[announce] ratelimit exceeded IP: %s\n", clientIP)
+		return nil, errRateLimited
+	case errors.Is(err, database.ErrPeerKeyMismatch):
+		fmt.Printf("[announce] rejected announce with mismatched key: peer_id=%q ip=%s\n", req.PeerID, clientIP)
+		return nil, errPeerKeyMismatch
+	case err != nil:
+		return nil, fmt.Errorf("failed to update peer: %v", err)
+	}
+
+	// 根据 uploaded/downloaded 计算本次增量并记账（失败不影响 announce）
+	h.accountTraffic(ctx, req, userID, ttl)
+
+	seeders, leechers := stored.Seeders, stored.Leechers
+	h.swarmSizes.put(req.InfoHash, seeders+leechers)
+	if op.Stopped {
+		return &announceResult{
+			Peers:       []string{},
+			Seeders:     seeders,
+			Leechers:    leechers,
+			Interval:    interval,
+			MinInterval: minInterval,
+		}, nil
+	}
+
+	peers := stored.Peers
+	if !storeSamples {
+		// 按种子 / 部署配置的策略选取 Peer，多取 len(ownPeers) 个，用于排除自己
+		peerReq := &PeerRequest{
+			InfoHash:   req.InfoHash,
+			Linked:     linked,
+			IsSeeder:   isSeeder,
+			NumWant:    numWant + len(ownPeers),
+			Downloaded: req.Downloaded,
+			Left:       req.Left,
+			IPs:        ownAddrs,
+		}
+
+		peers, err = selector.SelectPeers(ctx, peerReq)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get peers: %v", err)
+		}
 	}
 
-	// 排除当前客户端自己
+	// 排除当前客户端自己；滚动升级期间旧版实例写入的文本成员转换为存储格式，响应编码只需按长度区分地址族
 	filteredPeers := make([]string, 0, len(peers))
 	for _, p := range peers {
-		if p != peer {
+		p, ok := database.NormalizePeerMember(p)
+		if ok && !slices.Contains(ownPeers, p) {
 			filteredPeers = append(filteredPeers, p)
 		}
 	}
@@ -118,14 +261,16 @@ func (h *Handler) Announce(w http.ResponseWriter, r *http.Request) {
 		filteredPeers = filteredPeers[:numWant]
 	}
 
-	// 从 Redis 直接计算统计信息（Peer 集合中的实际数量）
-	seeders, leechers := h.countStats(ctx, req.InfoHash)
+	fmt.Printf("[announce] info_hash=%s total_peers=%d returned=%d seeders=%d leechers=%d interval=%s\n",
+		req.InfoHash[:16]+"...", len(peers), len(filteredPeers), seeders, leechers, interval)
 
-	fmt.Printf("[announce] info_hash=%s total_peers=%d returned=%d seeders=%d leechers=%d\n",
-		req.InfoHash[:16]+"...", len(peers), len(filteredPeers), seeders, leechers)
-
-	// 发送响应
-	h.sendSuccess(w, req, filteredPeers, seeders, leechers)
+	return &announceResult{
+		Peers:       filteredPeers,
+		Seeders:     seeders,
+		Leechers:    leechers,
+		Interval:    interval,
+		MinInterval: minInterval,
+	}, nil
 }
 
 // parseAnnounceRequest 解析 Announce 请求参数
@@ -147,10 +292,13 @@ func parseAnnounceRequest(r *http.Request) (*models.AnnounceRequest, error) {
 		return nil, fmt.Errorf("invalid port: %s", portStr)
 	}
 
-	// 转换 info_hash 为 Hex 字符串
-	// 真实 BT 客户端发送 20 字节原始二进制（URL 编码），需要转为 hex
-	// curl 测试可能直接发送 40 字符 hex 字符串，不需要再转
-	infoHashHex := normalizeInfoHash(infoHash)
+	// 转换 info_hash 为存储标识
+	// 真实 BT 客户端发送 20 / 32 字节原始二进制（URL 编码），需要转为 hex
+	// curl 测试可能直接发送 40 / 64 字符 hex 字符串，不需要再转
+	infoHashHex, err := normalizeInfoHash(infoHash)
+	if err != nil {
+		return nil, err
+	}
 
 	req := &models.AnnounceRequest{
 		InfoHash:   infoHashHex,
@@ -160,164 +308,43 @@ func parseAnnounceRequest(r *http.Request) (*models.AnnounceRequest, error) {
 		Downloaded: parseInt64(query.Get("downloaded")),
 		Left:       parseInt64(query.Get("left")),
 		Event:      query.Get("event"),
+		Key:        query.Get("key"),
 		Compact:    parseInt(query.Get("compact")),
+		NoPeerID:   parseInt(query.Get("no_peer_id")),
 		NumWant:    parseInt(query.Get("numwant")),
 	}
 
 	return req, nil
 }
 
-// getClientIP 获取客户端真实 IP
-func getClientIP(r *http.Request) string {
-	// 优先使用查询参数中的 IP（客户端可能在 NAT 后）
-	if ip := r.URL.Query().Get("ip"); ip != "" {
-		return ip
-	}
-
-	// 检查 X-Forwarded-For 头（代理）
-	if xff := r.Header.Get("X-Forwarded-For"); xff != "" {
-		ips := strings.Split(xff, ",")
-		return strings.TrimSpace(ips[0])
-	}
-
-	// 检查 X-Real-IP 头
-	if xri := r.Header.Get("X-Real-IP"); xri != "" {
-		return xri
-	}
-
-	// 使用连接的远程地址
-	host, _, err := net.SplitHostPort(r.RemoteAddr)
-	if err != nil {
-		return r.RemoteAddr
-	}
-	return host
-}
-
 // sendSuccess 发送成功响应（支持 IPv4 和 IPv6，BEP-0007）
-func (h *Handler) sendSuccess(w http.ResponseWriter, req *models.AnnounceRequest, peers []string, seeders, leechers int64) {
-	// 构建响应字典
-	response := make(map[string][]byte)
-
-	// 设置心跳间隔（秒），使用配置中的值
-	response["interval"] = EncodeInt(int64(h.config.Server.AnnounceInterval.Seconds()))
-	response["min interval"] = EncodeInt(int64(h.config.Server.AnnounceMinInterval.Seconds()))
-	response["complete"] = EncodeInt(seeders)    // Seeders
-	response["incomplete"] = EncodeInt(leechers) // Leechers
-
-	// 分离 IPv4 和 IPv6 Peer（BEP-0007）
-	ipv4Peers, ipv6Peers := SeparatePeersByIPVersion(peers)
-
-	// 处理 Peer 列表
-	if req.Compact == 1 {
-		// Compact 模式：返回二进制格式（BEP-0023 + BEP-0007）
-
-		// IPv4 Peers
-		if len(ipv4Peers) > 0 {
-			compactPeers, err := CompactPeersIPv4(ipv4Peers)
-			if err != nil {
-				h.sendError(w, fmt.Sprintf("failed to compact IPv4 peers: %v", err))
-				return
-			}
-			response["peers"] = EncodeBytes(compactPeers)
-		} else {
-			// 即使没有 IPv4 peer，也返回空字符串
-			response["peers"] = EncodeBytes([]byte{})
-		}
-
-		// IPv6 Peers（BEP-0007）
-		if len(ipv6Peers) > 0 {
-			compactPeers6, err := CompactPeersIPv6(ipv6Peers)
-			if err != nil {
-				h.sendError(w, fmt.Sprintf("failed to compact IPv6 peers: %v", err))
-				return
-			}
-			response["peers6"] = EncodeBytes(compactPeers6)
-		}
-	} else {
-		// 标准模式：返回字典列表
-		// IPv4 Peers
-		ipv4PeerList := make([][]byte, 0, len(ipv4Peers))
-		for _, peer := range ipv4Peers {
-			host, portStr, err := net.SplitHostPort(peer)
-			if err != nil {
-				continue
-			}
-			port, _ := strconv.Atoi(portStr)
-
-			peerDict := make(map[string][]byte)
-			peerDict["ip"] = EncodeString(host)
-			peerDict["port"] = EncodeInt(int64(port))
-			peerDict["peer id"] = EncodeString("") // 可选
-
-			ipv4PeerList = append(ipv4PeerList, EncodeDict(peerDict))
-		}
-		response["peers"] = EncodeList(ipv4PeerList)
-
-		// IPv6 Peers（BEP-0007）
-		if len(ipv6Peers) > 0 {
-			ipv6PeerList := make([][]byte, 0, len(ipv6Peers))
-			for _, peer := range ipv6Peers {
-				host, portStr, err := net.SplitHostPort(peer)
-				if err != nil {
-					continue
-				}
-				port, _ := strconv.Atoi(portStr)
-
-				peerDict := make(map[string][]byte)
-				peerDict["ip"] = EncodeString(host)
-				peerDict["port"] = EncodeInt(int64(port))
-				peerDict["peer id"] = EncodeString("") // 可选
-
-				ipv6PeerList = append(ipv6PeerList, EncodeDict(peerDict))
-			}
-			response["peers6"] = EncodeList(ipv6PeerList)
-		}
+func (h *Handler) sendSuccess(w http.ResponseWriter, req *models.AnnounceRequest, result *announceResult, peerIDs map[string]string, externalIP netip.Addr) {
+	reply := AnnounceReply{
+		Interval:    result.Interval,
+		MinInterval: result.MinInterval,
+		Seeders:     result.Seeders,
+		Leechers:    result.Leechers,
+		ExternalIP:  externalIP,
+		Peers:       result.Peers,
+		PeerIDs:     peerIDs,
+		Compact:     req.Compact == 1,
+		NoPeerID:    req.NoPeerID == 1,
 	}
 
-	// 编码为 Bencode
-	data := EncodeDict(response)
+	enc := acquireResponseEncoder()
+	defer releaseResponseEncoder(enc)
 
-	// 发送响应
-	w.Header().Set("Content-Type", "text/plain")
-	w.Header().Set("Content-Length", strconv.Itoa(len(data)))
-	w.WriteHeader(http.StatusOK)
-	w.Write(data)
+	enc.EncodeAnnounce(&reply)
+	writeBencode(w, enc.Bytes())
 }
 
 // sendError 发送错误响应
 func (h *Handler) sendError(w http.ResponseWriter, reason string) {
-	response := make(map[string][]byte)
-	response["failure reason"] = EncodeString(reason)
-
-	data := EncodeDict(response)
+	enc := acquireResponseEncoder()
+	defer releaseResponseEncoder(enc)
 
-	w.Header().Set("Content-Type", "text/plain")
-	w.Header().Set("Content-Length", strconv.Itoa(len(data)))
-	w.WriteHeader(http.StatusOK) // Tracker 错误仍返回 200
-	w.Write(data)
-}
-
-// normalizeInfoHash 统一处理 info_hash 参数
-// 真实 BT 客户端: 发送 20 字节原始二进制 → 需要 hex 编码为 40 字符
-// curl 测试: 可能直接发送 40 字符 hex 字符串 → 直接使用
-func normalizeInfoHash(raw string) string {
-	// 如果长度是 40 且全是合法 hex 字符，说明已经是 hex 格式
-	if len(raw) == 40 && isHexString(raw) {
-		return strings.ToLower(raw)
-	}
-
-	// 否则是原始二进制（20 字节），转为 hex
-	return hex.EncodeToString([]byte(raw))
-}
-
-// isHexString 检查字符串是否全部由合法 hex 字符组成
-func isHexString(s string) bool {
-	for _, c := range s {
-		if !((c >= '0' && c <= '9') || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F')) {
-			return false
-		}
-	}
-	return true
+	enc.EncodeFailure(reason)
+	writeBencode(w, enc.Bytes()) // Tracker 错误仍返回 200
 }
 
 // parseInt 解析整数参数
@@ -338,37 +365,21 @@ func parseInt64(s string) int64 {
 	return n
 }
 
-// countStats 从 Redis 直接计算统计信息
-// 使用 ZSet 精确计算 Seeders 和 Leechers
-func (h *Handler) countStats(ctx context.Context, infoHash string) (seeders, leechers int64) {
-	s, l, err := h.db.Redis.GetPeerCount(ctx, infoHash)
-	if err != nil {
-		return 0, 0
+// linkedInfoHashes 返回与 infoHash 共享 Peer 池的关联哈希（混合种子的另一半）
+func (h *Handler) linkedInfoHashes(infoHash string) []string {
+	if partner, ok := h.registry.Partner(infoHash); ok {
+		return []string{partner}
 	}
-
-	return s, l
+	return nil
 }
 
-// StartCleanup 启动定期清理过期 Peer 的任务
-// 定期收割 ZSet 中超时的死节点，维持健康的 Peer 列表
-func (h *Handler) StartCleanup(ctx context.Context, interval time.Duration) {
-	ticker := time.NewTicker(interval)
-	defer ticker.Stop()
-
-	// 死亡判定线：超过 2 倍心跳间隔没报备的视为死寂节点
-	timeout := interval * 2
-
-	for {
-		select {
-		case <-ctx.Done():
-			return
-		case <-ticker.C:
-			if err := h.db.Redis.CleanExpiredPeers(ctx, timeout); err != nil {
-				fmt.Printf("[cleanup] failed to clean expired peers: %v\n", err)
-			} else {
-				// 正常运行时可注释掉，避免刷屏
-				// fmt.Println("[cleanup] Successfully executed GC for dead peers.")
-			}
-		}
+// torrentStats 批量获取种子的完整统计（做种/下载人数 + 完成次数），用于 scrape，结果与 infoHashes 一一对应
+// 人数实时计算，完成次数来自 Peer 存储的完成计数（Redis 中为 tracker:{sXX}:stats:{info_hash} 的 completed 字段），
+// 全部种子在一次存储往返中读取；混合种子无论用哪个哈希查询，都返回两个哈希的合计
+func (h *Handler) torrentStats(ctx context.Context, infoHashes []string) ([]models.TorrentStats, error) {
+	targets := make([]database.StatsTarget, len(infoHashes))
+	for i, infoHash := range infoHashes {
+		targets[i] = database.StatsTarget{InfoHash: infoHash, Linked: h.linkedInfoHashes(infoHash)}
 	}
+	return h.peers.GetTorrentStats(ctx, targets)
 }
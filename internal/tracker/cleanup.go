@@ -0,0 +1,131 @@
+package tracker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"llmpt/internal/database"
+)
+
+// 过期 Peer 清理
+//
+// 每 CLEANUP_INTERVAL 运行一次，每次最多耗时 CLEANUP_BUDGET，按活跃种子分片用 SSCAN 增量扫描，未扫完的部分下次继续。
+// 多个 Tracker 实例共享 Redis 时，由清理租约（tracker:cleanup:lease）保证同一时间只有一个实例清理；
+// 持有者每次清理时续期，停止后租约在 3 倍 CLEANUP_INTERVAL 内过期，由其他实例接手。
+// 移除的 Peer 数与种子数记入日志并由 /stats/cleanup 汇总，用于观察 Swarm 流失
+
+// cleanupLeaseIntervals 清理租约时长（以 CLEANUP_INTERVAL 为单位）
+const cleanupLeaseIntervals = 3
+
+// LastSweep 最近一次清理的结果
+type LastSweep struct {
+	At             time.Time `json:"at"`
+	DurationMs     int64     `json:"duration_ms"`
+	Scanned        int       `json:"scanned"` // 检查的种子数
+	PeersReaped    int64     `json:"peers_reaped"`
+	TorrentsReaped int64     `json:"torrents_reaped"`
+}
+
+// CleanupStatsResponse /stats/cleanup 响应：本实例启动以来的累计清理结果
+type CleanupStatsResponse struct {
+	Sweeps         int64      `json:"sweeps"`  // 执行的清理次数
+	Skipped        int64      `json:"skipped"` // 租约被其他实例持有而跳过的次数
+	Rounds         int64      `json:"rounds"`  // 扫完全部活跃种子的整轮数
+	PeersReaped    int64      `json:"peers_reaped"`
+	TorrentsReaped int64      `json:"torrents_reaped"`
+	LastSweep      *LastSweep `json:"last_sweep,omitempty"`
+}
+
+// cleanupMonitor 累计清理结果
+type cleanupMonitor struct {
+	mu    sync.Mutex
+	stats CleanupStatsResponse
+}
+
+// record 记录一次清理
+func (m *cleanupMonitor) record(result *database.SweepResult, start time.Time, duration time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if result.Skipped {
+		m.stats.Skipped++
+		return
+	}
+	m.stats.Sweeps++
+	if result.Wrapped {
+		m.stats.Rounds++
+	}
+	m.stats.PeersReaped += result.PeersReaped
+	m.stats.TorrentsReaped += result.TorrentsReaped
+	m.stats.LastSweep = &LastSweep{
+		At:             start,
+		DurationMs:     duration.Milliseconds(),
+		Scanned:        result.Scanned,
+		PeersReaped:    result.PeersReaped,
+		TorrentsReaped: result.TorrentsReaped,
+	}
+}
+
+// snapshot 返回累计结果的副本
+func (m *cleanupMonitor) snapshot() CleanupStatsResponse {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stats := m.stats
+	if stats.LastSweep != nil {
+		last := *stats.LastSweep
+		stats.LastSweep = &last
+	}
+	return stats
+}
+
+// StartCleanup 启动定期清理过期 Peer 的任务
+// 定期收割 ZSet 中超时的死节点，维持健康的 Peer 列表
+func (h *Handler) StartCleanup(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	// 每个 Peer 的过期时间在 announce 时按下发的 interval 写入，清理任务只移除已过期的 Peer
+	sweep := &database.PeerSweep{
+		Budget: h.config.Server.CleanupBudget,
+		Lease:  cleanupLeaseIntervals * interval,
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			start := time.Now()
+			result, err := h.peers.CleanExpiredPeers(ctx, sweep)
+			if err != nil {
+				fmt.Printf("[cleanup] failed to clean expired peers: %v\n", err)
+			}
+			if result == nil {
+				continue
+			}
+			duration := time.Since(start)
+			h.cleanup.record(result, start, duration)
+			if result.PeersReaped > 0 || result.TorrentsReaped > 0 {
+				fmt.Printf("[cleanup] reaped %d peers and %d torrents (scanned %d torrents in %v)\n",
+					result.PeersReaped, result.TorrentsReaped, result.Scanned, duration.Round(time.Millisecond))
+			}
+		}
+	}
+}
+
+// CleanupStats 处理 /stats/cleanup 请求：本实例的过期 Peer 清理累计结果，用于绘制 Swarm 流失曲线
+// 需要携带管理员令牌（X-Admin-Token）。多实例部署时只有持有清理租约的实例在清理，应汇总各实例的结果
+func (h *Handler) CleanupStats(w http.ResponseWriter, r *http.Request) {
+	if !h.isAdminRequest(r) {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(h.cleanup.snapshot())
+}
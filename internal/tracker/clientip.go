@@ -0,0 +1,213 @@
+package tracker
+
+import (
+	"fmt"
+	"net/http"
+	"net/netip"
+	"strings"
+
+	"llmpt/internal/config"
+)
+
+// 客户端 IP 解析
+//
+// 写入 Swarm 的地址会被其他 Peer 主动连接，若可被任意伪造，Tracker 就成了反射攻击的跳板。
+// 解析顺序：
+//  1. 连接的远程地址
+//  2. 仅当远程地址属于 TRUSTED_PROXIES 时，才采信 X-Forwarded-For（从右往左跳过受信任代理，
+//     取第一个非代理地址）或 X-Real-IP
+//  3. ip= 参数按 IP_PARAM_POLICY 决定是否覆盖上面的结果
+//  4. ipv4= / ipv6= 参数（BEP-0007）可为双栈客户端额外登记另一地址族的地址
+//
+// 被拒绝的伪造尝试都会记录日志
+
+// observedClientIP 返回 Tracker 实际看到的客户端地址（已应用受信任代理逻辑，不含 ip= 参数）
+func (h *Handler) observedClientIP(r *http.Request) netip.Addr {
+	remote, err := netip.ParseAddrPort(r.RemoteAddr)
+	if err != nil {
+		// 测试环境下 RemoteAddr 可能不带端口
+		addr, err := netip.ParseAddr(r.RemoteAddr)
+		if err != nil {
+			return netip.Addr{}
+		}
+		return addr.Unmap()
+	}
+	addr := remote.Addr().Unmap()
+
+	xff := r.Header.Values("X-Forwarded-For")
+	xri := r.Header.Get("X-Real-IP")
+
+	if !h.isTrustedProxy(addr) {
+		if len(xff) > 0 || xri != "" {
+			fmt.Printf("[clientip] ignored forwarding headers from untrusted peer %s (X-Forwarded-For=%q X-Real-IP=%q)\n",
+				addr, strings.Join(xff, ","), xri)
+		}
+		return addr
+	}
+
+	if len(xff) > 0 {
+		// 从右往左遍历：最右侧由最近的代理追加，可信度最高；
+		// 跳过受信任代理，第一个非代理地址即为客户端，其左侧的内容可能由客户端伪造，不再采信
+		hops := strings.Split(strings.Join(xff, ","), ",")
+		for i := len(hops) - 1; i >= 0; i-- {
+			hop, err := netip.ParseAddr(strings.TrimSpace(hops[i]))
+			if err != nil {
+				fmt.Printf("[clientip] invalid X-Forwarded-For hop %q from proxy %s\n", hops[i], addr)
+				break
+			}
+			addr = hop.Unmap()
+			if !h.isTrustedProxy(addr) {
+				break
+			}
+		}
+		return addr
+	}
+
+	if xri != "" {
+		if realIP, err := netip.ParseAddr(strings.TrimSpace(xri)); err == nil {
+			return realIP.Unmap()
+		}
+		fmt.Printf("[clientip] invalid X-Real-IP %q from proxy %s\n", xri, addr)
+	}
+	return addr
+}
+
+// clientAddr 返回写入 Swarm 的主地址：observed（observedClientIP 的结果），或按策略采信的 ip= 参数
+func (h *Handler) clientAddr(r *http.Request, observed netip.Addr) netip.Addr {
+	if param := r.URL.Query().Get("ip"); param != "" {
+		if addr, ok := h.acceptIPParam("ip", param, observed); ok {
+			return addr
+		}
+	}
+	return observed
+}
+
+// announceIPs 返回本次 announce 需要登记的全部地址，第一个为主地址
+// BEP-0007：双栈客户端可通过 ipv4= / ipv6= 告知自己另一地址族的地址
+//   - 与主地址同一地址族的参数等同于 ip=，按 IP_PARAM_POLICY 决定是否覆盖主地址
+//   - 另一地址族的参数无法通过连接验证，由 DUAL_STACK_ANNOUNCE 单独控制、不受 IP_PARAM_POLICY 约束
+//     （见 acceptSecondaryAddr），必须是可路由的单播地址（拒绝回环、未指定、组播等）
+func (h *Handler) announceIPs(r *http.Request, observed netip.Addr) []string {
+	primary := h.clientAddr(r, observed)
+	if !primary.IsValid() {
+		return []string{r.RemoteAddr}
+	}
+
+	query := r.URL.Query()
+	var secondary netip.Addr
+	for _, param := range []struct {
+		name   string
+		family string
+		is4    bool
+	}{{"ipv4", "IPv4", true}, {"ipv6", "IPv6", false}} {
+		value := query.Get(param.name)
+		if value == "" {
+			continue
+		}
+
+		addr, ok := parseFamilyAddr(value, param.is4)
+		if !ok {
+			fmt.Printf("[clientip] rejected %s=%q from %s: not an %s address\n", param.name, value, primary, param.family)
+			continue
+		}
+
+		if addr.Is4() == primary.Is4() {
+			if addr != primary {
+				if accepted, ok := h.acceptIPParam(param.name, addr.String(), primary); ok {
+					primary = accepted
+				}
+			}
+			continue
+		}
+
+		if h.acceptSecondaryAddr(param.name, addr, observed) {
+			secondary = addr
+		}
+	}
+
+	ips := []string{primary.String()}
+	if secondary.IsValid() && secondary.Is4() != primary.Is4() {
+		ips = append(ips, secondary.String())
+	}
+	return ips
+}
+
+// acceptSecondaryAddr 判断是否登记另一地址族的 ipv4= / ipv6= 参数
+// 该地址与连接不同地址族，IP_PARAM_POLICY 的私有 / 公网划分对它没有意义（默认的 private 会拒绝所有公网 IPv6），
+// 因此单独判断：DUAL_STACK_ANNOUNCE 开启时接受任何客户端登记的可路由单播地址；
+// 私有地址只在连接本身来自私有地址时接受，公网客户端不能借双栈参数登记内网地址
+func (h *Handler) acceptSecondaryAddr(name string, addr, observed netip.Addr) bool {
+	if !h.config.Server.DualStackAnnounce {
+		fmt.Printf("[clientip] rejected %s=%s from %s: dual-stack announce is disabled\n", name, addr, observed)
+		return false
+	}
+	if !addr.IsGlobalUnicast() {
+		fmt.Printf("[clientip] rejected %s=%s from %s: not a routable unicast address\n", name, addr, observed)
+		return false
+	}
+	if isPrivateAddr(addr) && !isPrivateAddr(observed) {
+		fmt.Printf("[clientip] rejected %s=%s from %s: private addresses are only accepted from private peers\n", name, addr, observed)
+		return false
+	}
+	return true
+}
+
+// parseFamilyAddr 解析 ipv4= / ipv6= 参数，要求地址族匹配
+// BEP-0007 允许携带端口（"[addr]:port" / "addr:port"），端口部分忽略，始终使用 port 参数
+func parseFamilyAddr(value string, is4 bool) (netip.Addr, bool) {
+	addr, err := netip.ParseAddr(value)
+	if err != nil {
+		addrPort, err := netip.ParseAddrPort(value)
+		if err != nil {
+			return netip.Addr{}, false
+		}
+		addr = addrPort.Addr()
+	}
+
+	if is4 {
+		addr = addr.Unmap()
+		return addr, addr.Is4()
+	}
+	return addr, addr.Is6() && !addr.Is4In6()
+}
+
+// acceptIPParam 按 IP_PARAM_POLICY 判断是否采信客户端自报的地址（ip=，或与连接同地址族的 ipv4= / ipv6=）
+// BEP-0003 允许填写域名，这里只接受 IP 字面量
+func (h *Handler) acceptIPParam(name, param string, observed netip.Addr) (netip.Addr, bool) {
+	addr, err := netip.ParseAddr(param)
+	if err != nil {
+		fmt.Printf("[clientip] rejected %s=%q from %s: not an IP address\n", name, param, observed)
+		return netip.Addr{}, false
+	}
+	addr = addr.Unmap()
+
+	switch h.config.Server.IPParamPolicy {
+	case config.IPParamAllow:
+		return addr, true
+	case config.IPParamPrivate:
+		if isPrivateAddr(addr) {
+			return addr, true
+		}
+		fmt.Printf("[clientip] rejected %s=%s from %s: only private addresses are allowed\n", name, addr, observed)
+	default:
+		if addr != observed {
+			fmt.Printf("[clientip] rejected %s=%s from %s: ip parameter is disabled\n", name, addr, observed)
+		}
+	}
+	return netip.Addr{}, false
+}
+
+// isTrustedProxy 判断地址是否属于受信任的反向代理
+func (h *Handler) isTrustedProxy(addr netip.Addr) bool {
+	for _, prefix := range h.config.Server.TrustedProxies {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// isPrivateAddr 判断是否为私有 / 回环 / 链路本地地址（RFC 1918、RFC 4193 等）
+func isPrivateAddr(addr netip.Addr) bool {
+	return addr.IsPrivate() || addr.IsLoopback() || addr.IsLinkLocalUnicast()
+}
@@ -4,7 +4,10 @@ import (
 	"encoding/binary"
 	"fmt"
 	"net"
+	"net/netip"
 	"strconv"
+
+	"llmpt/internal/database"
 )
 
 // CompactPeer 将 Peer 信息编码为紧凑格式（BEP-0023）
@@ -258,3 +261,62 @@ func SeparatePeersByIPVersion(peers []string) (ipv4Peers, ipv6Peers []string) {
 	}
 	return
 }
+
+// AppendCompactPeer 将 "IP:Port" 格式的 Peer 以紧凑格式追加到 dst（不产生中间分配）
+// 返回追加后的切片以及该 Peer 是否为 IPv6；格式无效时 ok 为 false，dst 原样返回
+// IPv4-mapped IPv6 地址（::ffff:a.b.c.d）按 IPv4 处理，与 CompactPeer 保持一致
+func AppendCompactPeer(dst []byte, peer string) (out []byte, isIPv6 bool, ok bool) {
+	addrPort, err := netip.ParseAddrPort(peer)
+	if err != nil {
+		return dst, false, false
+	}
+	addr := addrPort.Addr().Unmap()
+	return appendCompactAddr(dst, addr, addrPort.Port()), addr.Is6(), true
+}
+
+// peerMember 将请求方地址编码为 Peer 存储格式
+func peerMember(addr netip.Addr, port int) string {
+	return database.PeerMember(netip.AddrPortFrom(addr, uint16(port)))
+}
+
+// parsePeerAddr 解析存储格式（Compact 二进制）的 Peer，见 database.PeerMember
+func parsePeerAddr(peer string) (netip.Addr, uint16, bool) {
+	addrPort, ok := database.ParsePeerMember(peer)
+	return addrPort.Addr(), addrPort.Port(), ok
+}
+
+// memberLen 存储格式 Peer 的长度：IPv4 为 6 字节，IPv6 为 18 字节
+func memberLen(ipv6 bool) int {
+	if ipv6 {
+		return database.PeerMemberIPv6Len
+	}
+	return database.PeerMemberIPv4Len
+}
+
+// appendCompactMembers 将指定地址族的存储格式 Peer 直接拼接到 dst，成员本身即 Compact 格式，无需解析
+func appendCompactMembers(dst []byte, peers []string, ipv6 bool) []byte {
+	size := memberLen(ipv6)
+	for _, peer := range peers {
+		if len(peer) == size {
+			dst = append(dst, peer...)
+		}
+	}
+	return dst
+}
+
+// appendCompactAddr 追加单个紧凑格式 Peer：IPv4 为 6 字节，IPv6 为 18 字节
+func appendCompactAddr(dst []byte, addr netip.Addr, port uint16) []byte {
+	dst = appendAddrBytes(dst, addr)
+	return append(dst, byte(port>>8), byte(port))
+}
+
+// appendAddrBytes 追加地址的二进制形式（IPv4 为 4 字节，IPv6 为 16 字节），IPv4-mapped 地址还原为 IPv4
+func appendAddrBytes(dst []byte, addr netip.Addr) []byte {
+	addr = addr.Unmap()
+	if addr.Is4() {
+		ip := addr.As4()
+		return append(dst, ip[:]...)
+	}
+	ip := addr.As16()
+	return append(dst, ip[:]...)
+}
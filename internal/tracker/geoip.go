@@ -0,0 +1,183 @@
+package tracker
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/netip"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/oschwald/maxminddb-golang"
+)
+
+// GeoIP 本地 MaxMind 格式（mmdb）国家 / ASN 数据库
+//
+// 用于 locality 策略按同 ASN、同国家就近返回 Peer，以及 /stats/geo 按国家、ASN 统计 Swarm。
+// GEOIP_DATABASES 可同时配置多个文件（如 GeoLite2-Country.mmdb 与 GeoLite2-ASN.mmdb），查询结果合并。
+// 只读取本地文件，不发起任何外部请求；按 GEOIP_RELOAD_INTERVAL 检查文件的修改时间和大小，变化后热加载，
+// 加载失败时保留旧数据。文件整体读入内存，运维直接覆盖写文件也不会影响正在进行的查询
+type GeoIP struct {
+	paths []string
+
+	mu  sync.RWMutex
+	dbs map[string]*geoDatabase // 文件路径 -> 已加载的数据库
+}
+
+// geoDatabase 单个已加载的 mmdb 文件
+type geoDatabase struct {
+	reader  *maxminddb.Reader
+	modTime time.Time
+	size    int64
+}
+
+// GeoInfo 地址的地理位置与网络归属，查不到的字段为零值
+type GeoInfo struct {
+	Country string // ISO 3166-1 国家代码，如 CN、US
+	ASN     uint32 // 自治系统号
+	ASOrg   string // 自治系统所属组织
+}
+
+// geoRecord mmdb 记录中用到的字段，兼容 GeoLite2 / DB-IP 的 Country、City、ASN 数据库
+type geoRecord struct {
+	Country struct {
+		ISOCode string `maxminddb:"iso_code"`
+	} `maxminddb:"country"`
+	ASN   uint32 `maxminddb:"autonomous_system_number"`
+	ASOrg string `maxminddb:"autonomous_system_organization"`
+}
+
+// NewGeoIP 创建 GeoIP 查询器，paths 为空时所有查询返回零值
+func NewGeoIP(paths []string) *GeoIP {
+	return &GeoIP{
+		paths: paths,
+		dbs:   make(map[string]*geoDatabase),
+	}
+}
+
+// Enabled 是否配置了 GeoIP 数据库
+func (g *GeoIP) Enabled() bool {
+	return len(g.paths) > 0
+}
+
+// Load 加载全部数据库文件，任一文件失败即返回错误（用于启动时校验配置）
+func (g *GeoIP) Load() error {
+	dbs := make(map[string]*geoDatabase, len(g.paths))
+	for _, path := range g.paths {
+		db, err := openGeoDatabase(path)
+		if err != nil {
+			return err
+		}
+		dbs[path] = db
+	}
+
+	g.mu.Lock()
+	g.dbs = dbs
+	g.mu.Unlock()
+
+	fmt.Printf("[geoip] loaded %d database(s)\n", len(dbs))
+	return nil
+}
+
+// Reload 重新加载有变化的数据库文件，失败的文件保留旧数据
+func (g *GeoIP) Reload() {
+	for _, path := range g.paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			fmt.Printf("[geoip] failed to stat %s: %v\n", path, err)
+			continue
+		}
+
+		g.mu.RLock()
+		current := g.dbs[path]
+		g.mu.RUnlock()
+		if current != nil && info.ModTime().Equal(current.modTime) && info.Size() == current.size {
+			continue
+		}
+
+		db, err := openGeoDatabase(path)
+		if err != nil {
+			fmt.Printf("[geoip] failed to reload %s, keeping previous version: %v\n", path, err)
+			continue
+		}
+
+		g.mu.Lock()
+		g.dbs[path] = db
+		g.mu.Unlock()
+		fmt.Printf("[geoip] reloaded %s (%s, built %s)\n", path, db.reader.Metadata.DatabaseType,
+			time.Unix(int64(db.reader.Metadata.BuildEpoch), 0).UTC().Format(time.DateOnly))
+	}
+}
+
+// StartReload 定期检查数据库文件并热加载，直到 ctx 取消
+func (g *GeoIP) StartReload(ctx context.Context, interval time.Duration) {
+	if !g.Enabled() {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			g.Reload()
+		}
+	}
+}
+
+// Lookup 查询地址的国家与 ASN，多个数据库的结果合并（先配置的优先）
+func (g *GeoIP) Lookup(addr netip.Addr) GeoInfo {
+	var info GeoInfo
+	if !g.Enabled() || !addr.IsValid() {
+		return info
+	}
+	ip := net.IP(addr.Unmap().AsSlice())
+
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	for _, path := range g.paths {
+		db, ok := g.dbs[path]
+		if !ok {
+			continue
+		}
+		var record geoRecord
+		if err := db.reader.Lookup(ip, &record); err != nil {
+			continue
+		}
+		if info.Country == "" {
+			info.Country = record.Country.ISOCode
+		}
+		if info.ASN == 0 {
+			info.ASN = record.ASN
+			info.ASOrg = record.ASOrg
+		}
+	}
+	return info
+}
+
+// openGeoDatabase 读取并解析 mmdb 文件
+func openGeoDatabase(path string) (*geoDatabase, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat geoip database %s: %w", path, err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read geoip database %s: %w", path, err)
+	}
+	reader, err := maxminddb.FromBytes(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse geoip database %s: %w", path, err)
+	}
+	return &geoDatabase{reader: reader, modTime: info.ModTime(), size: info.Size()}, nil
+}
+
+// GeoIP 返回处理器使用的 GeoIP 查询器
+func (h *Handler) GeoIP() *GeoIP {
+	return h.geoip
+}
@@ -0,0 +1,86 @@
+package tracker
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// info_hash 标识规范（BEP-0003 + BEP-0052）
+//
+// 对外接受的形式：
+//   - v1 / 截断 v2：20 字节原始二进制，或 40 字符 hex
+//   - 完整 v2：32 字节原始二进制（SHA-256），或 64 字符 hex
+//
+// 内部存储标识（即 Redis 键中的 {info_hash} 部分）：
+//   - v1：40 字符小写 hex（与历史数据保持一致）
+//   - v2：v2: 前缀 + 64 字符小写 hex，与 v1 处于不同的键命名空间
+//
+// BEP-0052 规定 v2 客户端向 Tracker 汇报时使用截断到 20 字节的哈希，
+// 单看长度无法与 v1 区分。MongoDB 中登记了 info_hash_v2 的种子由注册表记录 截断 -> 完整 的映射，
+// 收到匹配的 20 字节哈希即解析到 v2 命名空间，使两种形式落在同一个 Swarm；
+// 未登记的 v2 种子的截断形式按 v1 处理
+
+const (
+	infoHashV1Len    = 20
+	infoHashV2Len    = 32
+	infoHashV2Prefix = "v2:"
+)
+
+// normalizeInfoHash 校验并统一处理 info_hash 参数，返回内部存储标识
+// 长度不是 20/32 字节（或 40/64 字符 hex）的输入直接拒绝，不再盲目 hex 编码
+func normalizeInfoHash(raw string) (string, error) {
+	switch len(raw) {
+	case infoHashV1Len:
+		return hex.EncodeToString([]byte(raw)), nil
+	case infoHashV2Len:
+		return infoHashV2Prefix + hex.EncodeToString([]byte(raw)), nil
+	case infoHashV1Len * 2:
+		if isHexString(raw) {
+			return strings.ToLower(raw), nil
+		}
+	case infoHashV2Len * 2:
+		if isHexString(raw) {
+			return infoHashV2Prefix + strings.ToLower(raw), nil
+		}
+	}
+	return "", fmt.Errorf("invalid info_hash length: %d (expected 20 or 32 bytes, or 40 or 64 hex chars)", len(raw))
+}
+
+// isHexString 检查字符串是否全部由合法 hex 字符组成
+func isHexString(s string) bool {
+	for _, c := range s {
+		if !((c >= '0' && c <= '9') || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F')) {
+			return false
+		}
+	}
+	return true
+}
+
+// isInfoHashV2 判断存储标识是否为完整 v2 哈希
+func isInfoHashV2(infoHash string) bool {
+	return strings.HasPrefix(infoHash, infoHashV2Prefix)
+}
+
+// truncatedInfoHash 返回 v2 哈希截断到 20 字节后的 40 字符 hex（BEP-0052 Tracker 形式）
+func truncatedInfoHash(infoHashV2 string) string {
+	return strings.TrimPrefix(infoHashV2, infoHashV2Prefix)[:infoHashV1Len*2]
+}
+
+// infoHashBytes 将存储标识还原为原始二进制（v1 为 20 字节，v2 为 32 字节）
+func infoHashBytes(infoHash string) ([]byte, error) {
+	return hex.DecodeString(strings.TrimPrefix(infoHash, infoHashV2Prefix))
+}
+
+// resolveInfoHash 将 20 字节形式的标识解析为其对应的完整 v2 标识（若已知）
+// 只采信本地注册表中登记的 v2 种子：截断形式单看长度与 v1 无法区分，
+// 若按客户端汇报的完整哈希建立映射，任何人都能伪造一个与现有 v1 哈希同前缀的 "v2 哈希"，把该 v1 Swarm 劫持到自己的命名空间
+func (h *Handler) resolveInfoHash(infoHash string) string {
+	if isInfoHashV2(infoHash) {
+		return infoHash
+	}
+	if full, ok := h.registry.ResolveTruncated(infoHash); ok {
+		return full
+	}
+	return infoHash
+}
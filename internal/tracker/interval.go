@@ -0,0 +1,155 @@
+package tracker
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// 自适应 announce 间隔
+//
+// interval = ANNOUNCE_INTERVAL × 规模系数 × 负载系数，取整到秒并叠加 ±ANNOUNCE_INTERVAL_JITTER% 的随机抖动，
+// 最后限制在 [ANNOUNCE_INTERVAL_FLOOR, ANNOUNCE_INTERVAL_CEILING]：
+//   - 规模系数：以 100 个 Peer 为基准按对数伸缩。小 Swarm 中新来一个做种者影响很大，interval 缩短
+//     （空 Swarm 0.25 倍、10 个 Peer 约 0.64 倍）；大 Swarm 拉长（1000 个约 1.4 倍、10000 个约 1.75 倍）
+//   - 负载系数：Redis 往返延迟超过 LOAD_LATENCY_TARGET，或每秒 announce 数超过 LOAD_RATE_TARGET 时，
+//     按超出的倍数拉长，最多 4 倍
+//   - 抖动：服务重启后客户端会在短时间内集中重新 announce，随机抖动避免它们此后一直同步到达
+//
+// ADAPTIVE_INTERVAL=false 时只使用 ANNOUNCE_INTERVAL 加抖动。
+// 每个 Peer 按本次实际下发的 interval 计算过期时间（见 peerTTL），interval 在写入存储前按该 Swarm 上一次的规模计算
+
+const (
+	intervalReferenceSwarm = 100  // 规模系数为 1 的 Swarm 大小
+	intervalMinSizeFactor  = 0.25 // 空 Swarm 的规模系数
+	maxLoadFactor          = 4.0  // 负载系数上限
+
+	loadSampleInterval = 5 * time.Second // 负载采样周期
+	loadSmoothing      = 0.3             // 负载 EWMA 中新样本的权重
+	loadPingTimeout    = time.Second     // Redis 探测超时，超时按该值计入延迟
+)
+
+// loadMonitor 服务器负载（announce 速率、Redis 往返延迟）的滑动平均
+type loadMonitor struct {
+	announces atomic.Int64  // 当前采样周期内的 announce 次数
+	rate      atomic.Uint64 // 每秒 announce 数（EWMA，math.Float64bits 编码）
+	latency   atomic.Int64  // Redis 往返延迟（EWMA，纳秒）
+}
+
+// observe 记录一次 announce
+func (m *loadMonitor) observe() {
+	m.announces.Add(1)
+}
+
+// sample 结束一个采样周期，更新滑动平均
+func (m *loadMonitor) sample(period, latency time.Duration) {
+	rate := float64(m.announces.Swap(0)) / period.Seconds()
+	m.rate.Store(math.Float64bits(ewma(math.Float64frombits(m.rate.Load()), rate)))
+	m.latency.Store(int64(ewma(float64(m.latency.Load()), float64(latency))))
+}
+
+// factor 计算负载系数：1 表示负载正常，最大 maxLoadFactor
+func (m *loadMonitor) factor(latencyTarget time.Duration, rateTarget int) float64 {
+	f := 1.0
+	if latencyTarget > 0 {
+		f = max(f, float64(m.latency.Load())/float64(latencyTarget))
+	}
+	if rateTarget > 0 {
+		f = max(f, math.Float64frombits(m.rate.Load())/float64(rateTarget))
+	}
+	return min(f, maxLoadFactor)
+}
+
+func ewma(prev, sample float64) float64 {
+	return prev + loadSmoothing*(sample-prev)
+}
+
+// StartLoadMonitor 定期测量 Redis 往返延迟和 announce 速率，直到 ctx 取消
+func (h *Handler) StartLoadMonitor(ctx context.Context) {
+	ticker := time.NewTicker(loadSampleInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			pingCtx, cancel := context.WithTimeout(ctx, loadPingTimeout)
+			latency, err := h.peers.Ping(pingCtx)
+			cancel()
+			if err != nil {
+				fmt.Printf("[interval] peer store ping failed: %v\n", err)
+				latency = loadPingTimeout
+			}
+			h.load.sample(loadSampleInterval, latency)
+		}
+	}
+}
+
+// announceInterval 计算本次响应的 interval 与 min interval，swarmSize 为做种者与下载者之和
+func (h *Handler) announceInterval(swarmSize int64) (interval, minInterval time.Duration) {
+	cfg := &h.config.Server
+
+	interval = cfg.AnnounceInterval
+	if cfg.AdaptiveInterval {
+		sizeFactor := intervalMinSizeFactor +
+			(1-intervalMinSizeFactor)*math.Log1p(float64(swarmSize))/math.Log1p(intervalReferenceSwarm)
+		loadFactor := h.load.factor(cfg.LoadLatencyTarget, cfg.LoadRateTarget)
+		interval = time.Duration(float64(interval) * sizeFactor * loadFactor)
+	}
+	if cfg.AnnounceIntervalJitter > 0 {
+		jitter := (rand.Float64()*2 - 1) * float64(cfg.AnnounceIntervalJitter) / 100
+		interval += time.Duration(jitter * float64(interval))
+	}
+
+	lo, hi := cfg.AnnounceIntervalBounds()
+	interval = min(max(interval.Truncate(time.Second), lo), hi)
+
+	// min interval 不能大于 interval，否则客户端会在 interval 到期时被拒绝
+	return interval, min(cfg.AnnounceMinInterval, interval)
+}
+
+// peerTTL Peer 的存活时间：在下发 interval 的 1.5 倍内没有再次 announce 即视为死节点
+// 存储以 "announce 时间 + peerTTL" 作为 Peer 的过期时间，清理任务移除已过期的 Peer
+func peerTTL(interval time.Duration) time.Duration {
+	return interval + interval/2
+}
+
+// swarmSizeCacheLimit 每一代规模缓存的种子数上限
+const swarmSizeCacheLimit = 1 << 16
+
+// swarmSizeCache 各 Swarm 最近一次 announce 后的规模（做种者 + 下载者）
+// interval 决定了 Peer 的过期时间，必须在写入存储之前算出，此时只能用上一次 announce 看到的规模；
+// 两代 map 轮换，当前一代写满后丢弃更早的一代，内存有上限且常用的种子不会被淘汰
+type swarmSizeCache struct {
+	mu       sync.Mutex
+	current  map[string]int64
+	previous map[string]int64
+}
+
+// get 返回 Swarm 最近的规模，未知时返回 intervalReferenceSwarm（规模系数为 1）
+func (c *swarmSizeCache) get(infoHash string) int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if size, ok := c.current[infoHash]; ok {
+		return size
+	}
+	if size, ok := c.previous[infoHash]; ok {
+		return size
+	}
+	return intervalReferenceSwarm
+}
+
+// put 记录 Swarm 的规模
+func (c *swarmSizeCache) put(infoHash string, size int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.current == nil || len(c.current) >= swarmSizeCacheLimit {
+		c.previous, c.current = c.current, make(map[string]int64)
+	}
+	c.current[infoHash] = size
+}
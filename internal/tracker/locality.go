@@ -0,0 +1,177 @@
+package tracker
+
+import (
+	"context"
+	"net/netip"
+
+	"llmpt/internal/config"
+	"llmpt/internal/database"
+)
+
+// 就近选取 Peer（locality 策略）
+//
+// 跨机房拉取模型又慢又贵，locality 策略按以下优先级返回 Peer：
+//  1. 与请求方位于同一 /24（IPv4）或 /48（IPv6）
+//  2. 与请求方属于同一站点（SITE_CIDRS 定义的数据中心网段）
+//  3. 与请求方属于同一 ASN（需配置 GEOIP_DATABASES）
+//  4. 与请求方位于同一国家（需配置 GEOIP_DATABASES）
+//  5. 其他 Peer
+//
+// 至少 LOCALITY_RANDOM_PERCENT 的名额留给不属于以上 1~4 级的随机 Peer，避免 Swarm 按站点割裂成互不相连的孤岛；
+// 这类 Peer 不足时，剩余名额再由较近的 Peer 补足
+
+// 距离等级，数值越小越近
+const (
+	tierSubnet = iota
+	tierSite
+	tierASN
+	tierCountry
+	tierRemote
+)
+
+// 候选池相对 numwant 的放大倍数与上限
+const (
+	localityPoolFactor = 4
+	localityPoolMax    = 200
+)
+
+// localitySelector 优先返回同网段 / 同站点 / 同 ASN / 同国家的 Peer，余下名额用随机 Peer 补足
+type localitySelector struct {
+	peers         database.PeerStore
+	geoip         *GeoIP
+	sites         []config.Site
+	randomPercent int
+}
+
+func newLocalitySelector(peers database.PeerStore, cfg *config.Config, geoip *GeoIP) *localitySelector {
+	return &localitySelector{
+		peers:         peers,
+		geoip:         geoip,
+		sites:         cfg.Server.Sites,
+		randomPercent: cfg.Server.LocalityRandomPercent,
+	}
+}
+
+func (s *localitySelector) SelectPeers(ctx context.Context, req *PeerRequest) ([]string, error) {
+	// 随机取一个更大的候选池（做种者 / 下载者比例与 random 策略相同），在池内按距离排序
+	poolSize := req.NumWant * localityPoolFactor
+	if poolSize > localityPoolMax {
+		poolSize = localityPoolMax
+	}
+
+	pool, err := s.peers.GetPeersForRequest(ctx, req.InfoHash, int64(poolSize), req.IsSeeder, req.Linked...)
+	if err != nil {
+		return nil, err
+	}
+
+	sites := s.sitesOf(req.IPs)
+	geo := s.geoOf(req.IPs)
+	var tiers [tierRemote + 1][]string
+	for _, peer := range pool {
+		tier := s.tier(peer, req.IPs, sites, geo)
+		tiers[tier] = append(tiers[tier], peer)
+	}
+
+	var local []string
+	for _, peers := range tiers[:tierRemote] {
+		local = append(local, peers...)
+	}
+	remote := tiers[tierRemote]
+
+	localQuota := req.NumWant - req.NumWant*s.randomPercent/100
+	if len(local) < localQuota {
+		localQuota = len(local)
+	}
+
+	peers := make([]string, 0, len(pool))
+	peers = append(peers, local[:localQuota]...)
+	peers = append(peers, remote...)
+	peers = append(peers, local[localQuota:]...)
+	if len(peers) > req.NumWant {
+		peers = peers[:req.NumWant]
+	}
+	return peers, nil
+}
+
+// tier 计算候选 Peer 相对请求方的距离等级
+func (s *localitySelector) tier(peer string, ips []netip.Addr, sites []*config.Site, geo []GeoInfo) int {
+	addr, _, ok := parsePeerAddr(peer)
+	switch {
+	case !ok:
+		return tierRemote
+	case sameNetwork(addr, ips):
+		return tierSubnet
+	case inSites(addr, sites):
+		return tierSite
+	case len(geo) == 0:
+		return tierRemote
+	}
+
+	info := s.geoip.Lookup(addr)
+	tier := tierRemote
+	for _, g := range geo {
+		if info.ASN != 0 && info.ASN == g.ASN {
+			return tierASN
+		}
+		if info.Country != "" && info.Country == g.Country {
+			tier = tierCountry
+		}
+	}
+	return tier
+}
+
+// geoOf 查询请求方各地址的国家与 ASN，未配置 GeoIP 时返回 nil
+func (s *localitySelector) geoOf(ips []netip.Addr) []GeoInfo {
+	if !s.geoip.Enabled() {
+		return nil
+	}
+	geo := make([]GeoInfo, 0, len(ips))
+	for _, ip := range ips {
+		geo = append(geo, s.geoip.Lookup(ip))
+	}
+	return geo
+}
+
+// sitesOf 返回请求方任一地址所属的站点
+func (s *localitySelector) sitesOf(ips []netip.Addr) []*config.Site {
+	var sites []*config.Site
+	for i := range s.sites {
+		for _, ip := range ips {
+			if inSites(ip, []*config.Site{&s.sites[i]}) {
+				sites = append(sites, &s.sites[i])
+				break
+			}
+		}
+	}
+	return sites
+}
+
+// inSites 判断地址是否属于任一站点
+func inSites(addr netip.Addr, sites []*config.Site) bool {
+	for _, site := range sites {
+		for _, prefix := range site.Prefixes {
+			if prefix.Contains(addr) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// sameNetwork 判断地址是否与任一请求方地址位于同一 /24（IPv4）或 /48（IPv6）
+func sameNetwork(addr netip.Addr, ips []netip.Addr) bool {
+	for _, ip := range ips {
+		if ip.Is4() != addr.Is4() {
+			continue
+		}
+		bits := 48
+		if ip.Is4() {
+			bits = 24
+		}
+		prefix, err := ip.Prefix(bits)
+		if err == nil && prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,72 @@
+package tracker
+
+import (
+	"context"
+	"fmt"
+)
+
+// 私有 Tracker passkey 认证
+// Announce URL 形如 /announce/{passkey}，passkey 为 32 字符 hex
+// 查询结果缓存在 Redis（tracker:passkey:{passkey}），热路径上不访问 MongoDB；
+// 未知或已吊销的 passkey 同样做负缓存，防止暴力枚举打穿到 MongoDB。
+// 未使用 Redis（PEER_STORE=memory）时不缓存，每次回源 MongoDB
+
+// passkeyLen passkey 长度（16 字节随机数的 hex）
+const passkeyLen = 32
+
+// authenticate 校验 passkey，返回对应的用户 ID
+// 未携带 passkey 时：开启 REQUIRE_PASSKEY 则拒绝，否则以匿名身份放行（userID 为空）
+// 返回的 error 文本会直接作为 failure reason 发给客户端
+func (h *Handler) authenticate(ctx context.Context, passkey string) (string, error) {
+	if passkey == "" {
+		if h.config.Server.RequirePasskey {
+			return "", fmt.Errorf("passkey required: use your personal announce URL /announce/{passkey}")
+		}
+		return "", nil
+	}
+
+	if len(passkey) != passkeyLen || !isHexString(passkey) {
+		return "", fmt.Errorf("invalid passkey")
+	}
+
+	// 先查 Redis 缓存
+	var userID string
+	var found bool
+	if h.db.Redis != nil {
+		var err error
+		userID, found, err = h.db.Redis.GetCachedPasskey(ctx, passkey)
+		if err != nil {
+			fmt.Printf("[passkey] cache lookup failed: %v\n", err)
+		}
+	}
+	if found {
+		if userID == "" {
+			return "", fmt.Errorf("unknown or revoked passkey")
+		}
+		return userID, nil
+	}
+
+	// 缓存未命中，回源 MongoDB
+	user, err := h.db.MongoDB.FindUserByPasskey(ctx, passkey)
+	if err != nil {
+		fmt.Printf("[passkey] lookup failed: %v\n", err)
+		return "", fmt.Errorf("internal server error")
+	}
+
+	if user == nil || user.Revoked {
+		userID = ""
+	} else {
+		userID = user.ID.Hex()
+	}
+	if h.db.Redis != nil {
+		if err := h.db.Redis.CachePasskey(ctx, passkey, userID, h.config.Server.PasskeyCacheTTL); err != nil {
+			fmt.Printf("[passkey] failed to cache passkey: %v\n", err)
+		}
+	}
+
+	if userID == "" {
+		fmt.Printf("[passkey] rejected unknown or revoked passkey: %s...\n", passkey[:8])
+		return "", fmt.Errorf("unknown or revoked passkey")
+	}
+	return userID, nil
+}
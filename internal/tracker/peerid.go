@@ -0,0 +1,154 @@
+package tracker
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// peer_id 客户端识别（BEP-0020）
+//
+// 常见两种编码：
+//   - Azureus 风格："-" + 2 字符客户端代码 + 4 字符版本号 + "-"，如 -qB4650- 表示 qBittorrent 4.6.5
+//   - Shadow 风格：1 字符客户端代码 + 最多 5 字符版本号（每字符一位），以 "--" 结束，如 S58B----- 表示 Shadow 5.8.11
+//
+// 无法识别的 peer_id 归为 unknownClient
+
+const unknownClient = "unknown"
+
+// azureusClients Azureus 风格的客户端代码
+var azureusClients = map[string]string{
+	"AZ": "Vuze",
+	"BC": "BitComet",
+	"BI": "BiglyBT",
+	"BT": "BitTorrent",
+	"DE": "Deluge",
+	"FD": "Free Download Manager",
+	"GT": "anacrolix/torrent",
+	"KT": "KTorrent",
+	"LT": "libtorrent",
+	"lt": "libTorrent (rakshasa)",
+	"MC": "model-cli",
+	"qB": "qBittorrent",
+	"SD": "Thunder",
+	"TR": "Transmission",
+	"UT": "µTorrent",
+	"UW": "µTorrent Web",
+	"WW": "WebTorrent",
+	"XL": "Xunlei",
+}
+
+// shadowClients Shadow 风格的客户端代码
+var shadowClients = map[byte]string{
+	'A': "ABC",
+	'O': "Osprey Permaseed",
+	'Q': "BTQueue",
+	'R': "Tribler",
+	'S': "Shadow",
+	'T': "BitTornado",
+	'U': "UPnP NAT Bit Torrent",
+}
+
+// ClientInfo 从 peer_id 解析出的客户端信息
+type ClientInfo struct {
+	Name    string // 客户端名称，无法识别时为 "unknown"
+	Version string // 版本号，无法解析时为空
+}
+
+// String 返回 "名称 版本" 形式，用于日志和 failure reason
+func (c ClientInfo) String() string {
+	if c.Version == "" {
+		return c.Name
+	}
+	return c.Name + " " + c.Version
+}
+
+// ParsePeerID 识别 peer_id 对应的客户端及版本
+func ParsePeerID(peerID string) ClientInfo {
+	if info, ok := parseAzureusPeerID(peerID); ok {
+		return info
+	}
+	if info, ok := parseShadowPeerID(peerID); ok {
+		return info
+	}
+	return ClientInfo{Name: unknownClient}
+}
+
+// parseAzureusPeerID 解析 Azureus 风格：-XXVVVV-
+func parseAzureusPeerID(peerID string) (ClientInfo, bool) {
+	if len(peerID) < 8 || peerID[0] != '-' || peerID[7] != '-' {
+		return ClientInfo{}, false
+	}
+
+	code := peerID[1:3]
+	name, ok := azureusClients[code]
+	if !ok {
+		name = fmt.Sprintf("unknown (%s)", code)
+	}
+
+	// 每个字符是一位版本号（0-9 或 A-Z 表示 10-35），末尾的 0 段省略（4650 -> 4.6.5）
+	var parts []string
+	for i := 3; i < 7; i++ {
+		n, err := strconv.ParseUint(peerID[i:i+1], 36, 8)
+		if err != nil {
+			return ClientInfo{Name: name}, true
+		}
+		parts = append(parts, strconv.FormatUint(n, 10))
+	}
+	for len(parts) > 2 && parts[len(parts)-1] == "0" {
+		parts = parts[:len(parts)-1]
+	}
+	return ClientInfo{Name: name, Version: strings.Join(parts, ".")}, true
+}
+
+// parseShadowPeerID 解析 Shadow 风格：X + 版本字符 + "--"
+func parseShadowPeerID(peerID string) (ClientInfo, bool) {
+	if len(peerID) < 6 {
+		return ClientInfo{}, false
+	}
+	name, ok := shadowClients[peerID[0]]
+	if !ok {
+		return ClientInfo{}, false
+	}
+
+	// 版本字符：0-9 / A-Z / a-z / . / - 依次表示 0-63，遇到 "--" 结束
+	var parts []string
+	i := 1
+	for ; i <= 5 && i < len(peerID); i++ {
+		if peerID[i] == '-' {
+			break
+		}
+		n := strings.IndexByte(shadowVersionChars, peerID[i])
+		if n < 0 {
+			return ClientInfo{}, false
+		}
+		parts = append(parts, strconv.Itoa(n))
+	}
+	if len(parts) == 0 || !strings.HasPrefix(peerID[i:], "--") {
+		return ClientInfo{}, false
+	}
+	return ClientInfo{Name: name, Version: strings.Join(parts, ".")}, true
+}
+
+const shadowVersionChars = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz.-"
+
+// checkClient 按 CLIENT_DENYLIST / CLIENT_ALLOWLIST（peer_id 前缀）判断客户端是否允许使用本 Tracker
+// 命中黑名单一律拒绝；白名单非空时只允许命中白名单的客户端
+func (h *Handler) checkClient(peerID string) error {
+	for _, prefix := range h.config.Server.ClientDenylist {
+		if strings.HasPrefix(peerID, prefix) {
+			return fmt.Errorf("client not allowed: %s is banned on this tracker", ParsePeerID(peerID))
+		}
+	}
+
+	allowlist := h.config.Server.ClientAllowlist
+	if len(allowlist) == 0 {
+		return nil
+	}
+	for _, prefix := range allowlist {
+		if strings.HasPrefix(peerID, prefix) {
+			return nil
+		}
+	}
+	return fmt.Errorf("client not allowed: %s is not on the approved client list", ParsePeerID(peerID))
+}
@@ -0,0 +1,228 @@
+package tracker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"llmpt/internal/database"
+	"llmpt/internal/models"
+)
+
+// Registry 已在 MongoDB 中注册的种子的本地缓存
+// 用于在 announce 热路径上无需访问 MongoDB 即可查询：
+//   - 种子是否已登记（白名单模式）
+//   - 混合种子（v1 + v2）的关联哈希，使两边的 Peer 共享同一个 Peer 池
+//   - 截断 v2 哈希到完整 v2 标识的映射（BEP-0052）
+//   - 种子级的 Peer 选取策略覆盖
+//
+// 启动时全量加载，之后通过 Redis Pub/Sub 实时接收登记/删除事件，
+// 并按固定间隔从 MongoDB 全量刷新，兜底订阅断开期间丢失的事件
+type Registry struct {
+	db *database.DB
+
+	mu        sync.RWMutex
+	known     map[string]struct{} // 已登记的存储标识（v1 与完整 v2）
+	partners  map[string]string   // 存储标识 -> 关联的另一半存储标识
+	truncated map[string]string   // 截断 v2（40 字符 hex）-> 完整 v2 存储标识
+	selectors map[string]string   // 存储标识 -> Peer 选取策略名称（仅设置了 peer_selector 的种子）
+}
+
+// NewRegistry 创建种子注册表
+func NewRegistry(db *database.DB) *Registry {
+	return &Registry{
+		db:        db,
+		known:     make(map[string]struct{}),
+		partners:  make(map[string]string),
+		truncated: make(map[string]string),
+		selectors: make(map[string]string),
+	}
+}
+
+// Registered 判断存储标识对应的种子是否已在 MongoDB 中登记
+func (r *Registry) Registered(infoHash string) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	_, ok := r.known[infoHash]
+	return ok
+}
+
+// Partner 返回混合种子另一半的存储标识
+func (r *Registry) Partner(infoHash string) (string, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	partner, ok := r.partners[infoHash]
+	return partner, ok
+}
+
+// Selector 返回种子级的 Peer 选取策略名称
+func (r *Registry) Selector(infoHash string) (string, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	name, ok := r.selectors[infoHash]
+	return name, ok
+}
+
+// ResolveTruncated 查询截断 v2 哈希对应的完整 v2 存储标识
+func (r *Registry) ResolveTruncated(infoHash string) (string, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	full, ok := r.truncated[infoHash]
+	return full, ok
+}
+
+// Add 将单个种子加入注册表（发布后立即生效，无需等待下次刷新）
+func (r *Registry) Add(t *models.Torrent) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.add(t)
+}
+
+// Remove 将单个种子移出注册表（删除后立即生效）
+func (r *Registry) Remove(t *models.Torrent) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	v1, v2 := registryIDs(t)
+	if v1 != "" {
+		delete(r.known, v1)
+		delete(r.partners, v1)
+		delete(r.selectors, v1)
+	}
+	if v2 != "" {
+		delete(r.known, v2)
+		delete(r.partners, v2)
+		delete(r.selectors, v2)
+		delete(r.truncated, truncatedInfoHash(v2))
+	}
+}
+
+// add 在持有写锁的前提下登记单个种子
+func (r *Registry) add(t *models.Torrent) {
+	v1, v2 := registryIDs(t)
+	if v1 != "" {
+		r.known[v1] = struct{}{}
+		if t.PeerSelector != "" {
+			r.selectors[v1] = t.PeerSelector
+		}
+	}
+	if v2 == "" {
+		return
+	}
+
+	r.known[v2] = struct{}{}
+	if t.PeerSelector != "" {
+		r.selectors[v2] = t.PeerSelector
+	}
+	r.truncated[truncatedInfoHash(v2)] = v2
+
+	// 纯 v2 种子的 info_hash 字段可能直接填写截断形式，此时无需再关联
+	if v1 != "" && v1 != truncatedInfoHash(v2) {
+		r.partners[v1] = v2
+		r.partners[v2] = v1
+	}
+}
+
+// registryIDs 将 MongoDB 中的哈希字段转换为存储标识，格式不合法的字段返回空字符串
+func registryIDs(t *models.Torrent) (v1, v2 string) {
+	if len(t.InfoHash) == infoHashV1Len*2 && isHexString(t.InfoHash) {
+		v1 = strings.ToLower(t.InfoHash)
+	}
+	if len(t.InfoHashV2) == infoHashV2Len*2 && isHexString(t.InfoHashV2) {
+		v2 = infoHashV2Prefix + strings.ToLower(t.InfoHashV2)
+	}
+	return v1, v2
+}
+
+// errUnregisteredTorrent 白名单模式下未登记种子的 failure reason
+var errUnregisteredTorrent = fmt.Errorf("unregistered torrent: info_hash is not registered with this tracker")
+
+// allowTorrent 判断存储标识是否允许使用本 Tracker（未开启白名单模式时一律允许）
+func (h *Handler) allowTorrent(infoHash string) bool {
+	return !h.config.Server.WhitelistMode || h.registry.Registered(infoHash)
+}
+
+// Registry 返回处理器使用的种子注册表
+func (h *Handler) Registry() *Registry {
+	return h.registry
+}
+
+// Load 从 MongoDB 全量加载已登记的种子，替换当前缓存
+func (r *Registry) Load(ctx context.Context) error {
+	torrents, err := r.db.MongoDB.ListTorrentHashes(ctx)
+	if err != nil {
+		return err
+	}
+
+	next := &Registry{
+		known:     make(map[string]struct{}, len(torrents)),
+		partners:  make(map[string]string),
+		truncated: make(map[string]string),
+		selectors: make(map[string]string),
+	}
+	for i := range torrents {
+		next.add(&torrents[i])
+	}
+
+	r.mu.Lock()
+	r.known, r.partners, r.truncated, r.selectors = next.known, next.partners, next.truncated, next.selectors
+	r.mu.Unlock()
+	return nil
+}
+
+// StartSync 订阅种子登记/删除事件，并定期从 MongoDB 全量刷新注册表
+// 未使用 Redis（PEER_STORE=memory）时没有事件可订阅，只依赖定期刷新
+func (r *Registry) StartSync(ctx context.Context, interval time.Duration) {
+	if r.db.Redis != nil {
+		go r.watch(ctx)
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := r.Load(ctx); err != nil {
+				fmt.Printf("[registry] failed to refresh torrent registry: %v\n", err)
+			}
+		}
+	}
+}
+
+// watch 处理 Redis Pub/Sub 中的种子登记/删除事件，直到 ctx 取消
+func (r *Registry) watch(ctx context.Context) {
+	pubsub := r.db.Redis.SubscribeTorrentEvents(ctx)
+	defer pubsub.Close()
+
+	ch := pubsub.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case msg, ok := <-ch:
+			if !ok {
+				return
+			}
+
+			var event database.TorrentEvent
+			if err := json.Unmarshal([]byte(msg.Payload), &event); err != nil {
+				fmt.Printf("[registry] invalid torrent event: %v\n", err)
+				continue
+			}
+
+			t := &models.Torrent{InfoHash: event.InfoHash, InfoHashV2: event.InfoHashV2, PeerSelector: event.PeerSelector}
+			switch event.Action {
+			case database.TorrentEventPublish:
+				r.Add(t)
+			case database.TorrentEventDelete:
+				r.Remove(t)
+			}
+		}
+	}
+}
@@ -0,0 +1,233 @@
+package tracker
+
+import (
+	"net/http"
+	"net/netip"
+	"strconv"
+	"sync"
+	"time"
+
+	"llmpt/internal/bencode"
+	"llmpt/internal/database"
+)
+
+// Announce 响应的流式编码
+// 响应字典的键在包初始化时预编码并校验顺序，编码时按固定顺序直接写出，
+// 不再经过 map 构建 + 排序，配合 sync.Pool 复用缓冲区，热路径上零分配
+
+var announceKeys = bencode.SortedKeys("complete", "external ip", "incomplete", "interval", "min interval", "peers", "peers6")
+
+var (
+	keyComplete    = announceKeys[0]
+	keyExternalIP  = announceKeys[1]
+	keyIncomplete  = announceKeys[2]
+	keyInterval    = announceKeys[3]
+	keyMinInterval = announceKeys[4]
+	keyPeers       = announceKeys[5]
+	keyPeers6      = announceKeys[6]
+)
+
+var peerDictKeys = bencode.SortedKeys("ip", "peer id", "port")
+
+var (
+	keyPeerIP   = peerDictKeys[0]
+	keyPeerID   = peerDictKeys[1]
+	keyPeerPort = peerDictKeys[2]
+)
+
+var keyFailureReason = bencode.NewKey("failure reason")
+
+var scrapeFileKeys = bencode.SortedKeys("complete", "downloaded", "incomplete")
+
+var (
+	keyScrapeComplete   = scrapeFileKeys[0]
+	keyScrapeDownloaded = scrapeFileKeys[1]
+	keyScrapeIncomplete = scrapeFileKeys[2]
+)
+
+var keyFiles = bencode.NewKey("files")
+
+// AnnounceReply 一次成功 Announce 响应的内容
+type AnnounceReply struct {
+	Interval    time.Duration
+	MinInterval time.Duration
+	Seeders     int64
+	Leechers    int64
+	ExternalIP  netip.Addr        // Tracker 看到的客户端地址（BEP-0024），无效值时不输出
+	Peers       []string          // 存储格式（Compact 二进制，见 database.PeerMember），IPv4/IPv6 混合
+	PeerIDs     map[string]string // Peer 成员 -> peer_id，仅非 Compact 模式使用，缺失时写空字符串
+	Compact     bool
+	NoPeerID    bool // 非 Compact 模式下省略 peer id 键（no_peer_id=1）
+}
+
+// ScrapeFile Scrape 响应 files 字典中的一项
+type ScrapeFile struct {
+	InfoHash   string // 客户端所发送形式的原始二进制哈希（files 字典的键）
+	Seeders    int64
+	Leechers   int64
+	Downloaded int64
+}
+
+// ResponseEncoder 可复用的 Tracker 响应编码器
+// 在 bencode.Encoder 之外额外持有 IPv4/IPv6 两块临时缓冲区，用于拼接 Compact Peer 列表
+type ResponseEncoder struct {
+	*bencode.Encoder
+	scratch  []byte
+	scratch6 []byte
+}
+
+// NewResponseEncoder 创建响应编码器（只写内部缓冲区）
+func NewResponseEncoder() *ResponseEncoder {
+	return &ResponseEncoder{
+		Encoder:  bencode.NewEncoder(nil),
+		scratch:  make([]byte, 0, 50*6),
+		scratch6: make([]byte, 0, 50*18),
+	}
+}
+
+var responseEncoderPool = sync.Pool{
+	New: func() interface{} { return NewResponseEncoder() },
+}
+
+// acquireResponseEncoder 从池中取出已清空的编码器
+func acquireResponseEncoder() *ResponseEncoder {
+	enc := responseEncoderPool.Get().(*ResponseEncoder)
+	enc.Reset(nil)
+	return enc
+}
+
+// releaseResponseEncoder 归还编码器
+func releaseResponseEncoder(enc *ResponseEncoder) {
+	responseEncoderPool.Put(enc)
+}
+
+// EncodeAnnounce 编码成功响应（支持 IPv4 和 IPv6，BEP-0007）
+// Compact 模式下 peers 总是输出（无 IPv4 Peer 时为空字符串），peers6 仅在有 IPv6 Peer 时输出
+func (e *ResponseEncoder) EncodeAnnounce(reply *AnnounceReply) {
+	e.BeginDict()
+
+	e.WriteKey(keyComplete)
+	e.WriteInt(reply.Seeders)
+	if reply.ExternalIP.IsValid() {
+		// BEP-0024：IPv4 为 4 字节，IPv6 为 16 字节
+		e.WriteKey(keyExternalIP)
+		e.scratch = appendAddrBytes(e.scratch[:0], reply.ExternalIP)
+		e.WriteBytes(e.scratch)
+	}
+	e.WriteKey(keyIncomplete)
+	e.WriteInt(reply.Leechers)
+	e.WriteKey(keyInterval)
+	e.WriteInt(int64(reply.Interval.Seconds()))
+	e.WriteKey(keyMinInterval)
+	e.WriteInt(int64(reply.MinInterval.Seconds()))
+
+	if reply.Compact {
+		// Compact 模式：返回二进制格式（BEP-0023 + BEP-0007）
+		// Peer 以 Compact 格式存储，一次遍历按长度分别拷贝到 IPv4 和 IPv6 两个列表
+		e.compactPeers(reply.Peers)
+		e.WriteKey(keyPeers)
+		e.WriteBytes(e.scratch)
+		if len(e.scratch6) > 0 {
+			e.WriteKey(keyPeers6)
+			e.WriteBytes(e.scratch6)
+		}
+	} else {
+		// 标准模式：返回字典列表
+		e.WriteKey(keyPeers)
+		e.writePeerList(reply, false)
+		if e.hasPeers(reply.Peers, true) {
+			e.WriteKey(keyPeers6)
+			e.writePeerList(reply, true)
+		}
+	}
+
+	e.End()
+}
+
+// EncodeFailure 编码错误响应
+func (e *ResponseEncoder) EncodeFailure(reason string) {
+	e.BeginDict()
+	e.WriteKey(keyFailureReason)
+	e.WriteString(reason)
+	e.End()
+}
+
+// EncodeScrape 编码 Scrape 响应（BEP-0048）
+// files 字典的键为原始二进制哈希，调用方需保证 files 已按 InfoHash 升序排列且无重复
+func (e *ResponseEncoder) EncodeScrape(files []ScrapeFile) {
+	e.BeginDict()
+	e.WriteKey(keyFiles)
+	e.BeginDict()
+	for i := range files {
+		file := &files[i]
+		e.WriteString(file.InfoHash)
+		e.BeginDict()
+		e.WriteKey(keyScrapeComplete)
+		e.WriteInt(file.Seeders)
+		e.WriteKey(keyScrapeDownloaded)
+		e.WriteInt(file.Downloaded)
+		e.WriteKey(keyScrapeIncomplete)
+		e.WriteInt(file.Leechers)
+		e.End()
+	}
+	e.End()
+	e.End()
+}
+
+// hasPeers 判断列表中是否有指定地址族的 Peer
+func (e *ResponseEncoder) hasPeers(peers []string, ipv6 bool) bool {
+	size := memberLen(ipv6)
+	for _, peer := range peers {
+		if len(peer) == size {
+			return true
+		}
+	}
+	return false
+}
+
+// compactPeers 将 Peer 按地址族拼接为紧凑格式，结果分别存入 scratch（IPv4）和 scratch6（IPv6）
+// 存储格式即 Compact 格式，按长度区分地址族后直接拷贝
+func (e *ResponseEncoder) compactPeers(peers []string) {
+	v4, v6 := e.scratch[:0], e.scratch6[:0]
+	for _, peer := range peers {
+		switch len(peer) {
+		case database.PeerMemberIPv4Len:
+			v4 = append(v4, peer...)
+		case database.PeerMemberIPv6Len:
+			v6 = append(v6, peer...)
+		}
+	}
+	e.scratch, e.scratch6 = v4, v6
+}
+
+// writePeerList 将指定地址族的 Peer 写为字典列表（非 Compact 模式）
+func (e *ResponseEncoder) writePeerList(reply *AnnounceReply, ipv6 bool) {
+	e.BeginList()
+	for _, peer := range reply.Peers {
+		addr, port, ok := parsePeerAddr(peer)
+		if !ok || addr.Is6() != ipv6 {
+			continue
+		}
+
+		e.BeginDict()
+		e.WriteKey(keyPeerIP)
+		e.scratch = addr.AppendTo(e.scratch[:0])
+		e.WriteBytes(e.scratch)
+		if !reply.NoPeerID {
+			e.WriteKey(keyPeerID)
+			e.WriteString(reply.PeerIDs[peer])
+		}
+		e.WriteKey(keyPeerPort)
+		e.WriteInt(int64(port))
+		e.End()
+	}
+	e.End()
+}
+
+// writeBencode 以 Tracker 约定写出 Bencode 响应（错误也返回 200）
+func writeBencode(w http.ResponseWriter, data []byte) {
+	w.Header().Set("Content-Type", "text/plain")
+	w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+	w.WriteHeader(http.StatusOK)
+	w.Write(data)
+}
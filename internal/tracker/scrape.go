@@ -0,0 +1,145 @@
+package tracker
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"slices"
+	"sort"
+)
+
+// Scrape 处理 /scrape 请求（BEP-0048）
+// GET /scrape?info_hash=...&info_hash=...
+// 不带 info_hash 时为全量 scrape，仅在配置开启且携带管理员令牌时允许
+// 私有 Tracker 使用 /scrape/{passkey} 形式的个人 URL
+func (h *Handler) Scrape(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	admin := h.isAdminRequest(r)
+
+	// 与 announce 一致：认证前按客户端 IP 限流，避免被用于暴力枚举 passkey 或高频查询压垮存储；
+	// 携带管理员令牌的请求不限流
+	if !admin {
+		clientIP := r.RemoteAddr
+		if observed := h.observedClientIP(r); observed.IsValid() {
+			clientIP = observed.String()
+		}
+		if err := h.checkRateLimit(ctx, "scrape", clientIP); err != nil {
+			h.sendError(w, err.Error())
+			return
+		}
+	}
+
+	if _, err := h.authenticate(ctx, r.PathValue("passkey")); err != nil {
+		h.sendError(w, err.Error())
+		return
+	}
+
+	rawHashes := r.URL.Query()["info_hash"]
+
+	// 每个待查询种子：files 字典中的原始哈希键 + 解析后的存储标识
+	type scrapeTarget struct {
+		key      string
+		infoHash string
+	}
+	var targets []scrapeTarget
+
+	if len(rawHashes) == 0 {
+		// 全量 scrape：返回所有活跃种子
+		if !h.config.Server.ScrapeAllowFull {
+			h.sendError(w, "full scrape is disabled")
+			return
+		}
+		if !admin {
+			h.sendError(w, "full scrape requires admin token")
+			return
+		}
+
+		all, err := h.peers.GetActiveTorrents(ctx)
+		if err != nil {
+			fmt.Printf("[scrape] failed to list active torrents: %v\n", err)
+			h.sendError(w, "internal server error")
+			return
+		}
+		for _, infoHash := range all {
+			if !h.allowTorrent(infoHash) {
+				continue
+			}
+			rawHash, err := infoHashBytes(infoHash)
+			if err != nil {
+				continue
+			}
+			targets = append(targets, scrapeTarget{key: string(rawHash), infoHash: infoHash})
+		}
+	} else {
+		if max := h.config.Server.ScrapeMaxHashes; max > 0 && len(rawHashes) > max {
+			h.sendError(w, fmt.Sprintf("too many info_hash parameters (max %d)", max))
+			return
+		}
+
+		// 与 announce 一致：支持 20/32 字节原始二进制和 40/64 字符 hex
+		seen := make(map[string]bool, len(rawHashes))
+		for _, raw := range rawHashes {
+			infoHash, err := normalizeInfoHash(raw)
+			if err != nil {
+				h.sendError(w, err.Error())
+				return
+			}
+			if seen[infoHash] {
+				continue
+			}
+			seen[infoHash] = true
+
+			resolved := h.resolveInfoHash(infoHash)
+			if !h.allowTorrent(resolved) {
+				h.sendError(w, errUnregisteredTorrent.Error())
+				return
+			}
+
+			// files 字典的键是客户端所发送形式的原始二进制（截断 v2 仍为 20 字节）
+			rawHash, _ := infoHashBytes(infoHash)
+			targets = append(targets, scrapeTarget{key: string(rawHash), infoHash: resolved})
+		}
+	}
+
+	// files 字典的键必须按字节序排列且不重复
+	sort.Slice(targets, func(i, j int) bool { return targets[i].key < targets[j].key })
+
+	targets = slices.CompactFunc(targets, func(a, b scrapeTarget) bool { return a.key == b.key })
+
+	infoHashes := make([]string, len(targets))
+	for i, target := range targets {
+		infoHashes[i] = target.infoHash
+	}
+	stats, err := h.torrentStats(ctx, infoHashes)
+	if err != nil {
+		fmt.Printf("[scrape] failed to get torrent stats: %v\n", err)
+		h.sendError(w, "internal server error")
+		return
+	}
+
+	files := make([]ScrapeFile, len(targets))
+	for i, target := range targets {
+		files[i] = ScrapeFile{
+			InfoHash:   target.key,
+			Seeders:    stats[i].Seeders,
+			Leechers:   stats[i].Leechers,
+			Downloaded: stats[i].Completed,
+		}
+	}
+
+	enc := acquireResponseEncoder()
+	defer releaseResponseEncoder(enc)
+
+	enc.EncodeScrape(files)
+	writeBencode(w, enc.Bytes())
+}
+
+// isAdminRequest 判断请求是否携带正确的管理员令牌（X-Admin-Token 头）
+// 未配置 ADMIN_TOKEN 时一律视为非管理员
+func (h *Handler) isAdminRequest(r *http.Request) bool {
+	token := h.config.Server.AdminToken
+	if token == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(r.Header.Get("X-Admin-Token")), []byte(token)) == 1
+}
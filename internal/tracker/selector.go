@@ -0,0 +1,107 @@
+package tracker
+
+import (
+	"context"
+	"fmt"
+	"net/netip"
+
+	"llmpt/internal/config"
+	"llmpt/internal/database"
+)
+
+// Peer 选取策略
+//
+// announce 写入自身后，由 PeerSelector 从 Redis 的做种者 / 下载者 ZSet 中挑选返回给客户端的 Peer。
+// 部署级默认策略由 PEER_SELECTOR 指定，单个种子可在 MongoDB 中通过 peer_selector 字段覆盖
+
+// PeerRequest 选取 Peer 时请求方的上下文
+type PeerRequest struct {
+	InfoHash   string       // 存储标识（已解析截断 v2）
+	Linked     []string     // 共享 Peer 池的关联哈希（混合种子的另一半）
+	IsSeeder   bool         // 请求方是否为做种者（left == 0）
+	IPs        []netip.Addr // 请求方登记的地址，第一个为主地址
+	NumWant    int          // 需要的 Peer 数量（已包含用于排除自己的余量）
+	Downloaded int64
+	Left       int64
+}
+
+// Progress 估算请求方的下载进度（0~1），无法估算时返回 0
+func (r *PeerRequest) Progress() float64 {
+	if r.Left == 0 {
+		return 1
+	}
+	if total := r.Downloaded + r.Left; total > 0 && r.Downloaded > 0 {
+		return float64(r.Downloaded) / float64(total)
+	}
+	return 0
+}
+
+// PeerSelector Peer 选取策略
+// 返回的列表可能包含请求方自己的地址，由调用方排除
+type PeerSelector interface {
+	SelectPeers(ctx context.Context, req *PeerRequest) ([]string, error)
+}
+
+// newPeerSelectors 创建所有内置策略，键为策略名称
+func newPeerSelectors(peers database.PeerStore, cfg *config.Config, geoip *GeoIP) map[string]PeerSelector {
+	return map[string]PeerSelector{
+		config.PeerSelectorRandom:        &randomSelector{peers: peers},
+		config.PeerSelectorScarceSeeders: &scarceSeederSelector{peers: peers},
+		config.PeerSelectorFresh:         &freshSelector{peers: peers},
+		config.PeerSelectorLocality:      newLocalitySelector(peers, cfg, geoip),
+	}
+}
+
+// peerSelector 返回种子使用的选取策略：种子级覆盖优先，其次为部署级默认
+func (h *Handler) peerSelector(infoHash string) PeerSelector {
+	if name, ok := h.registry.Selector(infoHash); ok {
+		if selector, ok := h.selectors[name]; ok {
+			return selector
+		}
+		fmt.Printf("[selector] unknown peer selector %q for %s, using default\n", name, infoHash)
+	}
+	if selector, ok := h.selectors[h.config.Server.PeerSelector]; ok {
+		return selector
+	}
+	return h.selectors[config.PeerSelectorRandom]
+}
+
+// randomSelector 随机混合：做种者只拿下载者；下载者拿 30% 做种者 + 70% 下载者（历史默认行为）
+type randomSelector struct {
+	peers database.PeerStore
+}
+
+func (s *randomSelector) SelectPeers(ctx context.Context, req *PeerRequest) ([]string, error) {
+	return s.peers.GetPeersForRequest(ctx, req.InfoHash, int64(req.NumWant), req.IsSeeder, req.Linked...)
+}
+
+// scarceSeederSelector 节省做种者带宽：下载者优先拿其他下载者，下载者不足 numwant 时才用做种者补足
+// 适合做种节点少、上行带宽紧张的部署
+type scarceSeederSelector struct {
+	peers database.PeerStore
+}
+
+func (s *scarceSeederSelector) SelectPeers(ctx context.Context, req *PeerRequest) ([]string, error) {
+	peers, err := s.peers.RandomPeers(ctx, req.InfoHash, false, req.NumWant, req.Linked...)
+	if err != nil || req.IsSeeder || len(peers) >= req.NumWant {
+		return peers, err
+	}
+
+	seeders, err := s.peers.RandomPeers(ctx, req.InfoHash, true, req.NumWant-len(peers), req.Linked...)
+	if err != nil {
+		return nil, err
+	}
+	return append(peers, seeders...), nil
+}
+
+// freshSelector 优先返回心跳最新的 Peer，比例与 randomSelector 相同
+// 新近 announce 过的 Peer 更可能仍在线，可减少客户端连接失败
+type freshSelector struct {
+	peers database.PeerStore
+}
+
+func (s *freshSelector) SelectPeers(ctx context.Context, req *PeerRequest) ([]string, error) {
+	return database.MixPeers(req.NumWant, req.IsSeeder, func(seeders bool, count int) ([]string, error) {
+		return s.peers.FreshPeers(ctx, req.InfoHash, seeders, count, req.Linked...)
+	})
+}
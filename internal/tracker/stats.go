@@ -0,0 +1,339 @@
+package tracker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// /stats/clients 与 /stats/geo 需要读取种子中全部 Peer 的 peer_id（Redis 中每个种子一次 HGETALL），
+// 活跃种子很多时开销与 Peer 总数成正比。每次最多统计 STATS_SAMPLE_TORRENTS 个种子（超出时随机抽样），
+// 结果缓存 STATS_CACHE_TTL，监控系统频繁轮询时不会反复扫描
+
+// statsCacheMaxEntries 缓存的结果数上限（/stats/geo 按 info_hash 参数分别缓存）
+const statsCacheMaxEntries = 64
+
+// statsCache 统计结果缓存：请求 -> 已编码的 JSON
+type statsCache struct {
+	mu      sync.Mutex
+	entries map[string]statsCacheEntry
+}
+
+type statsCacheEntry struct {
+	body    []byte
+	expires time.Time
+}
+
+// get 返回未过期的缓存结果
+func (c *statsCache) get(key string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[key]
+	if !ok || time.Now().After(entry.expires) {
+		return nil, false
+	}
+	return entry.body, true
+}
+
+// put 缓存结果，达到上限时先清掉过期项，仍然已满则不缓存
+func (c *statsCache) put(key string, body []byte, ttl time.Duration) {
+	if ttl <= 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	if c.entries == nil {
+		c.entries = make(map[string]statsCacheEntry)
+	}
+	if len(c.entries) >= statsCacheMaxEntries {
+		for k, entry := range c.entries {
+			if now.After(entry.expires) {
+				delete(c.entries, k)
+			}
+		}
+		if len(c.entries) >= statsCacheMaxEntries {
+			return
+		}
+	}
+	c.entries[key] = statsCacheEntry{body: body, expires: now.Add(ttl)}
+}
+
+// sampleTorrents 种子数超过 STATS_SAMPLE_TORRENTS 时随机抽取该数量的种子（会打乱 infoHashes）
+func (h *Handler) sampleTorrents(infoHashes []string) (sample []string, sampled bool) {
+	limit := h.config.Server.StatsSampleTorrents
+	if len(infoHashes) <= limit {
+		return infoHashes, false
+	}
+	for i := 0; i < limit; i++ {
+		j := i + rand.Intn(len(infoHashes)-i)
+		infoHashes[i], infoHashes[j] = infoHashes[j], infoHashes[i]
+	}
+	return infoHashes[:limit], true
+}
+
+// serveStats 返回缓存结果，未命中时调用 compute 生成并缓存
+// compute 出错时自行写出错误响应并返回 nil
+func (h *Handler) serveStats(w http.ResponseWriter, key string, compute func() interface{}) {
+	body, ok := h.stats.get(key)
+	if !ok {
+		resp := compute()
+		if resp == nil {
+			return
+		}
+		var err error
+		body, err = json.Marshal(resp)
+		if err != nil {
+			fmt.Printf("[stats] failed to encode response: %v\n", err)
+			http.Error(w, "internal server error", http.StatusInternalServerError)
+			return
+		}
+		h.stats.put(key, body, h.config.Server.StatsCacheTTL)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(body)
+}
+
+// ClientStat 某个客户端的活跃 Peer 数量
+type ClientStat struct {
+	Client   string         `json:"client"`
+	Peers    int            `json:"peers"`
+	Versions map[string]int `json:"versions"` // 版本号 -> Peer 数量（无法解析版本时键为空字符串）
+}
+
+// ClientStatsResponse /stats/clients 响应
+type ClientStatsResponse struct {
+	ActiveTorrents int          `json:"active_torrents"` // 活跃种子数
+	Torrents       int          `json:"torrents"`        // 实际统计的种子数
+	Sampled        bool         `json:"sampled"`         // 活跃种子超过 STATS_SAMPLE_TORRENTS，仅统计随机抽取的部分
+	Peers          int          `json:"peers"`           // 统计到的 Peer 数（同一种子内按 peer_id 去重）
+	Clients        []ClientStat `json:"clients"`         // 按 Peer 数量降序
+}
+
+// ClientStats 处理 /stats/clients 请求：按客户端统计当前活跃 Peer，用于监控
+// 需要携带管理员令牌（X-Admin-Token）
+func (h *Handler) ClientStats(w http.ResponseWriter, r *http.Request) {
+	if !h.isAdminRequest(r) {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+
+	ctx := r.Context()
+	h.serveStats(w, "clients", func() interface{} {
+		infoHashes, err := h.peers.GetActiveTorrents(ctx)
+		if err != nil {
+			fmt.Printf("[stats] failed to list active torrents: %v\n", err)
+			http.Error(w, "internal server error", http.StatusInternalServerError)
+			return nil
+		}
+
+		resp := &ClientStatsResponse{ActiveTorrents: len(infoHashes)}
+		infoHashes, resp.Sampled = h.sampleTorrents(infoHashes)
+		resp.Torrents = len(infoHashes)
+
+		swarms, err := h.peers.GetSwarmPeers(ctx, infoHashes)
+		if err != nil {
+			fmt.Printf("[stats] failed to get peer ids: %v\n", err)
+			http.Error(w, "internal server error", http.StatusInternalServerError)
+			return nil
+		}
+
+		byClient := make(map[string]*ClientStat)
+		for _, peerIDs := range swarms {
+			// 双栈 Peer 以两个地址登记，同一种子内按 peer_id 去重
+			seen := make(map[string]bool, len(peerIDs))
+			for _, peerID := range peerIDs {
+				if seen[peerID] {
+					continue
+				}
+				seen[peerID] = true
+
+				info := ParsePeerID(peerID)
+				stat, ok := byClient[info.Name]
+				if !ok {
+					stat = &ClientStat{Client: info.Name, Versions: make(map[string]int)}
+					byClient[info.Name] = stat
+				}
+				stat.Peers++
+				stat.Versions[info.Version]++
+				resp.Peers++
+			}
+		}
+
+		resp.Clients = make([]ClientStat, 0, len(byClient))
+		for _, stat := range byClient {
+			resp.Clients = append(resp.Clients, *stat)
+		}
+		sort.Slice(resp.Clients, func(i, j int) bool {
+			if resp.Clients[i].Peers != resp.Clients[j].Peers {
+				return resp.Clients[i].Peers > resp.Clients[j].Peers
+			}
+			return resp.Clients[i].Client < resp.Clients[j].Client
+		})
+		return resp
+	})
+}
+
+// unknownCountry 无法识别国家（GeoIP 未命中）的 Peer 的归类
+const unknownCountry = "unknown"
+
+// CountryStat 某个国家的活跃 Peer 数量
+type CountryStat struct {
+	Country string `json:"country"` // ISO 3166-1 国家代码，无法识别时为 "unknown"
+	Peers   int    `json:"peers"`
+}
+
+// ASNStat 某个自治系统的活跃 Peer 数量
+type ASNStat struct {
+	ASN   uint32 `json:"asn"` // 无法识别时为 0
+	Org   string `json:"org"`
+	Peers int    `json:"peers"`
+}
+
+// GeoStatsResponse /stats/geo 响应
+type GeoStatsResponse struct {
+	ActiveTorrents int           `json:"active_torrents"` // 活跃种子数（指定 info_hash 时为该 Swarm 的哈希数）
+	Torrents       int           `json:"torrents"`        // 实际统计的种子数
+	Sampled        bool          `json:"sampled"`         // 活跃种子超过 STATS_SAMPLE_TORRENTS，仅统计随机抽取的部分
+	Peers          int           `json:"peers"`           // 统计到的 Peer 数（同一种子内按 peer_id 去重）
+	Countries      []CountryStat `json:"countries"`       // 按 Peer 数量降序
+	ASNs           []ASNStat     `json:"asns"`            // 按 Peer 数量降序
+}
+
+// GeoStats 处理 /stats/geo 请求：按国家、ASN 统计活跃 Peer（需配置 GEOIP_DATABASES）
+// 默认统计全部活跃种子，可用 info_hash 参数只统计单个 Swarm（混合种子包含两个哈希）
+// 需要携带管理员令牌（X-Admin-Token）
+func (h *Handler) GeoStats(w http.ResponseWriter, r *http.Request) {
+	if !h.isAdminRequest(r) {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+	if !h.geoip.Enabled() {
+		http.Error(w, "geoip database is not configured", http.StatusNotFound)
+		return
+	}
+
+	ctx := r.Context()
+
+	var infoHashes []string
+	if raw := r.URL.Query().Get("info_hash"); raw != "" {
+		infoHash, err := normalizeInfoHash(raw)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		infoHash = h.resolveInfoHash(infoHash)
+		infoHashes = append([]string{infoHash}, h.linkedInfoHashes(infoHash)...)
+	}
+
+	key := "geo"
+	if len(infoHashes) > 0 {
+		key += ":" + infoHashes[0]
+	}
+	h.serveStats(w, key, func() interface{} {
+		if resp := h.geoStats(ctx, w, infoHashes); resp != nil {
+			return resp
+		}
+		return nil
+	})
+}
+
+// geoStats 统计指定种子（为空时为全部活跃种子）中 Peer 的国家 / ASN 分布，出错时写出错误响应并返回 nil
+func (h *Handler) geoStats(ctx context.Context, w http.ResponseWriter, infoHashes []string) *GeoStatsResponse {
+	resp := &GeoStatsResponse{}
+	if len(infoHashes) == 0 {
+		var err error
+		infoHashes, err = h.peers.GetActiveTorrents(ctx)
+		if err != nil {
+			fmt.Printf("[stats] failed to list active torrents: %v\n", err)
+			http.Error(w, "internal server error", http.StatusInternalServerError)
+			return nil
+		}
+	}
+	resp.ActiveTorrents = len(infoHashes)
+	infoHashes, resp.Sampled = h.sampleTorrents(infoHashes)
+	resp.Torrents = len(infoHashes)
+
+	swarms, err := h.peers.GetSwarmPeers(ctx, infoHashes)
+	if err != nil {
+		fmt.Printf("[stats] failed to get swarm peers: %v\n", err)
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return nil
+	}
+
+	byCountry := make(map[string]*CountryStat)
+	byASN := make(map[uint32]*ASNStat)
+	for _, peers := range swarms {
+		// 双栈 Peer 以两个地址登记，两个地址归属相同时只计一次
+		seen := make(map[string]bool, len(peers))
+		seenCountry := make(map[[2]string]bool, len(peers)) // (peer_id, 国家)
+		seenASN := make(map[[2]string]bool, len(peers))     // (peer_id, ASN)
+		for peer, peerID := range peers {
+			if !seen[peerID] {
+				seen[peerID] = true
+				resp.Peers++
+			}
+
+			var info GeoInfo
+			if addr, _, ok := parsePeerAddr(peer); ok {
+				info = h.geoip.Lookup(addr)
+			}
+
+			country := info.Country
+			if country == "" {
+				country = unknownCountry
+			}
+			if key := [2]string{peerID, country}; !seenCountry[key] {
+				seenCountry[key] = true
+				stat, ok := byCountry[country]
+				if !ok {
+					stat = &CountryStat{Country: country}
+					byCountry[country] = stat
+				}
+				stat.Peers++
+			}
+
+			if key := [2]string{peerID, strconv.FormatUint(uint64(info.ASN), 10)}; !seenASN[key] {
+				seenASN[key] = true
+				stat, ok := byASN[info.ASN]
+				if !ok {
+					stat = &ASNStat{ASN: info.ASN, Org: info.ASOrg}
+					byASN[info.ASN] = stat
+				}
+				stat.Peers++
+			}
+		}
+	}
+
+	resp.Countries = make([]CountryStat, 0, len(byCountry))
+	for _, stat := range byCountry {
+		resp.Countries = append(resp.Countries, *stat)
+	}
+	sort.Slice(resp.Countries, func(i, j int) bool {
+		if resp.Countries[i].Peers != resp.Countries[j].Peers {
+			return resp.Countries[i].Peers > resp.Countries[j].Peers
+		}
+		return resp.Countries[i].Country < resp.Countries[j].Country
+	})
+
+	resp.ASNs = make([]ASNStat, 0, len(byASN))
+	for _, stat := range byASN {
+		resp.ASNs = append(resp.ASNs, *stat)
+	}
+	sort.Slice(resp.ASNs, func(i, j int) bool {
+		if resp.ASNs[i].Peers != resp.ASNs[j].Peers {
+			return resp.ASNs[i].Peers > resp.ASNs[j].Peers
+		}
+		return resp.ASNs[i].ASN < resp.ASNs[j].ASN
+	})
+
+	return resp
+}
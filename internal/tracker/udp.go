@@ -0,0 +1,345 @@
+package tracker
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	"llmpt/internal/models"
+)
+
+// UDP Tracker 协议实现 - BEP-0015
+// 规范: https://www.bittorrent.org/beps/bep_0015.html
+//
+// 所有整数均为大端序，报文格式：
+//   connect  请求: protocol_id(8) action(4)=0 transaction_id(4)
+//   announce 请求: connection_id(8) action(4)=1 transaction_id(4) info_hash(20) peer_id(20)
+//                  downloaded(8) left(8) uploaded(8) event(4) ip(4) key(4) num_want(4) port(2)
+//   scrape   请求: connection_id(8) action(4)=2 transaction_id(4) info_hash(20)*N
+//   error    响应: action(4)=3 transaction_id(4) message
+
+const (
+	udpProtocolID uint64 = 0x41727101980 // connect 请求的魔数
+
+	udpActionConnect  uint32 = 0
+	udpActionAnnounce uint32 = 1
+	udpActionScrape   uint32 = 2
+	udpActionError    uint32 = 3
+
+	udpConnectRequestLen  = 16
+	udpAnnounceRequestLen = 98
+	udpScrapeHeaderLen    = 16
+
+	// 单个 UDP 包最多 scrape 的 info_hash 数量（BEP-0015 建议约 74 个）
+	udpMaxScrapeHashes = 74
+
+	// connection_id 有效窗口：按分钟分桶，服务端接受当前与上一个桶（1~2 分钟）
+	udpConnectionIDBucket = time.Minute
+
+	udpMaxPacketSize = 2048
+)
+
+// UDP announce 的 event 字段取值
+var udpEvents = map[uint32]string{
+	0: "",
+	1: "completed",
+	2: "started",
+	3: "stopped",
+}
+
+// UDPServer UDP Tracker 服务器
+// 与 HTTP Handler 共用同一个 Handler（同一套 Redis 存储），
+// 因此 HTTP 和 UDP 客户端会出现在同一个 Swarm 中
+type UDPServer struct {
+	handler *Handler
+	conn    net.PacketConn
+	secret  []byte // 用于无状态签发 connection_id 的 HMAC 密钥
+}
+
+// NewUDPServer 创建 UDP Tracker 服务器
+func NewUDPServer(handler *Handler) (*UDPServer, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, fmt.Errorf("failed to generate connection id secret: %w", err)
+	}
+
+	return &UDPServer{
+		handler: handler,
+		secret:  secret,
+	}, nil
+}
+
+// Listen 绑定指定地址，addr 形如 ":6969"，在双栈系统上同时接受 IPv4 和 IPv6 客户端
+// 必须在启动 Serve 的 goroutine 之前调用，Close 因此总能看到已创建的套接字
+func (s *UDPServer) Listen(addr string) error {
+	conn, err := net.ListenPacket("udp", addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on udp %s: %w", addr, err)
+	}
+	s.conn = conn
+	return nil
+}
+
+// ListenAndServe 监听指定地址并处理 UDP 请求，直到 ctx 取消或 Close 被调用
+func (s *UDPServer) ListenAndServe(ctx context.Context, addr string) error {
+	if err := s.Listen(addr); err != nil {
+		return err
+	}
+	return s.Serve(ctx)
+}
+
+// Serve 处理 Listen 绑定的套接字上的 UDP 请求，直到 ctx 取消或 Close 被调用
+func (s *UDPServer) Serve(ctx context.Context) error {
+	conn := s.conn
+	if conn == nil {
+		return fmt.Errorf("udp server is not listening")
+	}
+
+	go func() {
+		<-ctx.Done()
+		conn.Close()
+	}()
+
+	buf := make([]byte, udpMaxPacketSize)
+	for {
+		n, remote, err := conn.ReadFrom(buf)
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			fmt.Printf("[udp] read error: %v\n", err)
+			continue
+		}
+
+		udpAddr, ok := remote.(*net.UDPAddr)
+		if !ok {
+			continue
+		}
+
+		// 复制报文后异步处理，避免慢请求阻塞读循环
+		packet := make([]byte, n)
+		copy(packet, buf[:n])
+		go s.handlePacket(ctx, packet, udpAddr)
+	}
+}
+
+// Close 关闭 UDP 监听
+func (s *UDPServer) Close() error {
+	if s.conn != nil {
+		return s.conn.Close()
+	}
+	return nil
+}
+
+// handlePacket 分发单个 UDP 请求
+func (s *UDPServer) handlePacket(ctx context.Context, packet []byte, addr *net.UDPAddr) {
+	// 长度不足以携带 action 和 transaction_id 的报文直接丢弃
+	if len(packet) < udpConnectRequestLen {
+		return
+	}
+
+	action := binary.BigEndian.Uint32(packet[8:12])
+	transactionID := binary.BigEndian.Uint32(packet[12:16])
+
+	// connect 请求不需要 connection_id
+	if action == udpActionConnect {
+		if binary.BigEndian.Uint64(packet[0:8]) != udpProtocolID {
+			s.sendError(addr, transactionID, "invalid protocol id")
+			return
+		}
+		s.handleConnect(addr, transactionID)
+		return
+	}
+
+	// 其余请求必须携带有效的 connection_id（防止 IP 伪造）
+	connectionID := binary.BigEndian.Uint64(packet[0:8])
+	if !s.validConnectionID(connectionID, addr) {
+		s.sendError(addr, transactionID, "invalid connection id")
+		return
+	}
+
+	switch action {
+	case udpActionAnnounce:
+		s.handleAnnounce(ctx, packet, addr, transactionID)
+	case udpActionScrape:
+		s.handleScrape(ctx, packet, addr, transactionID)
+	default:
+		s.sendError(addr, transactionID, fmt.Sprintf("unknown action: %d", action))
+	}
+}
+
+// handleConnect 处理 connect 请求，签发 connection_id
+func (s *UDPServer) handleConnect(addr *net.UDPAddr, transactionID uint32) {
+	resp := make([]byte, 16)
+	binary.BigEndian.PutUint32(resp[0:4], udpActionConnect)
+	binary.BigEndian.PutUint32(resp[4:8], transactionID)
+	binary.BigEndian.PutUint64(resp[8:16], s.connectionID(addr, time.Now()))
+	s.write(resp, addr)
+}
+
+// handleAnnounce 处理 announce 请求，复用 HTTP 的核心 Announce 逻辑
+func (s *UDPServer) handleAnnounce(ctx context.Context, packet []byte, addr *net.UDPAddr, transactionID uint32) {
+	if len(packet) < udpAnnounceRequestLen {
+		s.sendError(addr, transactionID, "invalid announce packet length")
+		return
+	}
+
+	event, ok := udpEvents[binary.BigEndian.Uint32(packet[80:84])]
+	if !ok {
+		s.sendError(addr, transactionID, "invalid event")
+		return
+	}
+
+	port := int(binary.BigEndian.Uint16(packet[96:98]))
+	if port < 1 {
+		s.sendError(addr, transactionID, "invalid port: 0")
+		return
+	}
+
+	// UDP 报文中的 info_hash 固定 20 字节（v1 或截断 v2），不会出错
+	infoHash, _ := normalizeInfoHash(string(packet[16:36]))
+
+	// 报文中的 ip 字段（84:88）忽略，始终使用报文源地址，避免地址伪造
+	req := &models.AnnounceRequest{
+		InfoHash:   infoHash,
+		PeerID:     string(packet[36:56]),
+		Downloaded: int64(binary.BigEndian.Uint64(packet[56:64])),
+		Left:       int64(binary.BigEndian.Uint64(packet[64:72])),
+		Uploaded:   int64(binary.BigEndian.Uint64(packet[72:80])),
+		Event:      event,
+		Key:        udpKey(binary.BigEndian.Uint32(packet[88:92])),
+		NumWant:    int(int32(binary.BigEndian.Uint32(packet[92:96]))), // -1 表示默认
+		Port:       port,
+		Compact:    1,
+	}
+
+	clientIP, isIPv4 := udpClientIP(addr)
+
+	result, err := s.handler.processAnnounce(ctx, req, []string{clientIP})
+	if err != nil {
+		s.sendError(addr, transactionID, err.Error())
+		return
+	}
+
+	// 按套接字地址族返回对应的 Compact Peer：IPv4 为 6 字节，IPv6 为 18 字节（存储格式即 Compact 格式，直接拷贝）
+	resp := make([]byte, 20, 20+len(result.Peers)*memberLen(!isIPv4))
+	binary.BigEndian.PutUint32(resp[0:4], udpActionAnnounce)
+	binary.BigEndian.PutUint32(resp[4:8], transactionID)
+	binary.BigEndian.PutUint32(resp[8:12], uint32(result.Interval.Seconds()))
+	binary.BigEndian.PutUint32(resp[12:16], uint32(result.Leechers))
+	binary.BigEndian.PutUint32(resp[16:20], uint32(result.Seeders))
+	resp = appendCompactMembers(resp, result.Peers, !isIPv4)
+	s.write(resp, addr)
+}
+
+// handleScrape 处理 scrape 请求，每个 info_hash 依次返回 seeders/completed/leechers
+func (s *UDPServer) handleScrape(ctx context.Context, packet []byte, addr *net.UDPAddr, transactionID uint32) {
+	hashes := packet[udpScrapeHeaderLen:]
+	if len(hashes) == 0 || len(hashes)%20 != 0 {
+		s.sendError(addr, transactionID, "invalid scrape packet length")
+		return
+	}
+
+	count := len(hashes) / 20
+	if count > udpMaxScrapeHashes {
+		count = udpMaxScrapeHashes
+	}
+
+	// 与 HTTP scrape 一致按客户端 IP 限流
+	clientIP, _ := udpClientIP(addr)
+	if err := s.handler.checkRateLimit(ctx, "udp", clientIP); err != nil {
+		s.sendError(addr, transactionID, err.Error())
+		return
+	}
+
+	infoHashes := make([]string, count)
+	for i := range infoHashes {
+		infoHash, _ := normalizeInfoHash(string(hashes[i*20 : (i+1)*20]))
+		infoHashes[i] = s.handler.resolveInfoHash(infoHash)
+		if !s.handler.allowTorrent(infoHashes[i]) {
+			s.sendError(addr, transactionID, errUnregisteredTorrent.Error())
+			return
+		}
+	}
+
+	resp := make([]byte, 8, 8+count*12)
+	binary.BigEndian.PutUint32(resp[0:4], udpActionScrape)
+	binary.BigEndian.PutUint32(resp[4:8], transactionID)
+
+	stats, err := s.handler.torrentStats(ctx, infoHashes)
+	if err != nil {
+		fmt.Printf("[udp] failed to get torrent stats: %v\n", err)
+		s.sendError(addr, transactionID, "internal server error")
+		return
+	}
+
+	for _, st := range stats {
+		entry := make([]byte, 12)
+		binary.BigEndian.PutUint32(entry[0:4], uint32(st.Seeders))
+		binary.BigEndian.PutUint32(entry[4:8], uint32(st.Completed))
+		binary.BigEndian.PutUint32(entry[8:12], uint32(st.Leechers))
+		resp = append(resp, entry...)
+	}
+
+	s.write(resp, addr)
+}
+
+// sendError 发送 error 响应
+func (s *UDPServer) sendError(addr *net.UDPAddr, transactionID uint32, message string) {
+	resp := make([]byte, 8, 8+len(message))
+	binary.BigEndian.PutUint32(resp[0:4], udpActionError)
+	binary.BigEndian.PutUint32(resp[4:8], transactionID)
+	resp = append(resp, message...)
+	s.write(resp, addr)
+}
+
+// write 发送 UDP 响应
+func (s *UDPServer) write(resp []byte, addr *net.UDPAddr) {
+	if _, err := s.conn.WriteTo(resp, addr); err != nil {
+		fmt.Printf("[udp] write to %s failed: %v\n", addr, err)
+	}
+}
+
+// connectionID 根据客户端地址和时间桶计算 connection_id
+// 采用 HMAC 无状态签发：无需在服务端保存任何连接表
+func (s *UDPServer) connectionID(addr *net.UDPAddr, t time.Time) uint64 {
+	bucket := make([]byte, 8)
+	binary.BigEndian.PutUint64(bucket, uint64(t.Unix()/int64(udpConnectionIDBucket.Seconds())))
+
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write(bucket)
+	mac.Write(addr.IP.To16())
+	mac.Write([]byte{byte(addr.Port >> 8), byte(addr.Port)})
+	return binary.BigEndian.Uint64(mac.Sum(nil)[:8])
+}
+
+// validConnectionID 校验 connection_id 是否由本服务器为该地址签发且未过期
+func (s *UDPServer) validConnectionID(id uint64, addr *net.UDPAddr) bool {
+	now := time.Now()
+	return id == s.connectionID(addr, now) ||
+		id == s.connectionID(addr, now.Add(-udpConnectionIDBucket))
+}
+
+// udpKey 将报文中的 32 位 key 转为与 HTTP key 参数常见写法一致的 8 位十六进制文本
+// 0 表示客户端没有 key，返回空串，避免所有不带 key 的客户端共用同一个 key
+func udpKey(key uint32) string {
+	if key == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%08X", key)
+}
+
+// udpClientIP 提取客户端 IP 字符串，并判断套接字地址族
+// 双栈套接字上的 IPv4 客户端会表现为 IPv4-mapped 地址（::ffff:a.b.c.d），统一还原为 IPv4
+func udpClientIP(addr *net.UDPAddr) (ip string, isIPv4 bool) {
+	if ipv4 := addr.IP.To4(); ipv4 != nil {
+		return ipv4.String(), true
+	}
+	return addr.IP.String(), false
+}